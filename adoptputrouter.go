@@ -2,18 +2,226 @@ package corekit
 
 import (
 	"net/http"
+	"regexp"
+	"strings"
+	"sync"
 
 	"github.com/bmizerany/pat"
 )
 
+// PatRouter is implemented by the default ServeMux. It is the supported way to
+// reach pat features the ServeMux interface does not cover:
+//
+//	if pr, ok := svc.ServeMux().(corekit.PatRouter); ok {
+//		pr.Pat().Get("/files/", filesHandler)
+//	}
+//
+// Routes added on the pat router directly go through the service middlewares
+// but not through the per-route instrumentation, the constraint syntax of Add
+// nor its automatic OPTIONS answers. Patterns ending with a slash match every
+// path under them; see AddPrefix.
+type PatRouter interface {
+	Pat() *pat.PatternServeMux
+	// AddPrefix registers h for every path under prefix, as pat does for
+	// patterns ending with a slash.
+	AddPrefix(meth string, prefix string, h http.Handler)
+}
+
 type adoptPatRouter struct {
 	router *pat.PatternServeMux
+
+	// mu guards patterns, the methods registered by pattern as given to Add.
+	mu       sync.RWMutex
+	patterns map[string]*patternMethods
+}
+
+// patternMethods are the methods registered for a pattern. An OPTIONS
+// handler registered after the others is kept in options and served by the
+// automatic one.
+type patternMethods struct {
+	methods []string
+	auto    bool
+	options http.Handler
+}
+
+func newPatRouter() *adoptPatRouter {
+	return &adoptPatRouter{router: pat.New(), patterns: map[string]*patternMethods{}}
+}
+
+// Pat returns the wrapped pat router.
+func (r *adoptPatRouter) Pat() *pat.PatternServeMux {
+	return r.router
+}
+
+// AddPrefix registers h for every path starting with prefix. pat.Tail gives
+// the remainder of the path to h.
+func (r *adoptPatRouter) AddPrefix(meth string, prefix string, h http.Handler) {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	r.Add(meth, prefix, h)
 }
 
+// Add registers h for the pat pattern path. Parameters may declare a type
+// constraint, e.g. "/users/:id(int)" or "/files/:name([a-z]+)"; requests whose
+// parameter does not satisfy it get a 404.
+//
+// OPTIONS requests on path are answered with 204 and an Allow header listing
+// the methods registered for it, unless an OPTIONS handler is added for path
+// too, whether before or after the other methods.
 func (r *adoptPatRouter) Add(meth string, path string, h http.Handler) {
-	r.router.Add(meth, path, h)
+	pattern := path
+	path, constraints := parseConstraints(path)
+	add := func(meth string, h http.Handler) {
+		if len(constraints) > 0 {
+			h = r.constrainParams(constraints, h)
+		}
+		r.router.Add(meth, path, h)
+	}
+
+	r.mu.Lock()
+	pm, ok := r.patterns[pattern]
+	if !ok {
+		pm = &patternMethods{}
+		r.patterns[pattern] = pm
+	}
+	if meth == http.MethodOptions {
+		if pm.options == nil {
+			pm.options = h
+		}
+		auto := pm.auto
+		r.mu.Unlock()
+		if !auto {
+			add(meth, h)
+		}
+		return
+	}
+	pm.methods = appendMissing(pm.methods, meth)
+	auto := !ok
+	pm.auto = pm.auto || auto
+	r.mu.Unlock()
+
+	add(meth, h)
+	if auto {
+		add(http.MethodOptions, r.allowHandler(pm))
+	}
+}
+
+// allowHandler answers OPTIONS requests for the methods of pm, or passes them
+// to the OPTIONS handler registered later.
+func (r *adoptPatRouter) allowHandler(pm *patternMethods) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.RLock()
+		options := pm.options
+		allow := strings.Join(append(append([]string(nil), pm.methods...), http.MethodOptions), ", ")
+		r.mu.RUnlock()
+
+		if options != nil {
+			options.ServeHTTP(w, req)
+			return
+		}
+		w.Header().Set("Allow", allow)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func appendMissing(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// NotFound sets the handler used when no pattern matches the request.
+func (r *adoptPatRouter) NotFound(h http.Handler) {
+	r.router.NotFound = h
 }
 
 func (r *adoptPatRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	r.router.ServeHTTP(w, req)
 }
+
+var namedConstraints = map[string]string{
+	"int":   `-?[0-9]+`,
+	"uint":  `[0-9]+`,
+	"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+	"alpha": `[a-zA-Z]+`,
+	"alnum": `[a-zA-Z0-9]+`,
+}
+
+// parseConstraints strips "(constraint)" suffixes from pattern parameters and
+// returns the plain pat pattern with a regexp per constrained parameter.
+func parseConstraints(path string) (string, map[string]*regexp.Regexp) {
+	var (
+		out         strings.Builder
+		constraints map[string]*regexp.Regexp
+	)
+	for i := 0; i < len(path); i++ {
+		out.WriteByte(path[i])
+		if path[i] != ':' {
+			continue
+		}
+
+		j := i + 1
+		for j < len(path) && isParamChar(path[j]) {
+			j++
+		}
+		name := path[i+1 : j]
+		out.WriteString(name)
+		i = j - 1
+		if j >= len(path) || path[j] != '(' {
+			continue
+		}
+
+		end, depth := j, 0
+		for ; end < len(path); end++ {
+			if path[end] == '(' {
+				depth++
+			} else if path[end] == ')' {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+		}
+		if end == len(path) {
+			panic("corekit: unterminated constraint in route " + path)
+		}
+
+		expr := path[j+1 : end]
+		if named, ok := namedConstraints[expr]; ok {
+			expr = named
+		}
+		if constraints == nil {
+			constraints = map[string]*regexp.Regexp{}
+		}
+		constraints[name] = regexp.MustCompile("^(?:" + expr + ")$")
+		i = end
+	}
+	return out.String(), constraints
+}
+
+func isParamChar(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// constrainParams answers requests whose parameters do not satisfy
+// constraints as if no pattern matched, with the NotFound handler if set.
+func (r *adoptPatRouter) constrainParams(constraints map[string]*regexp.Regexp, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		for name, re := range constraints {
+			if !re.MatchString(q.Get(":" + name)) {
+				if r.router.NotFound != nil {
+					r.router.NotFound.ServeHTTP(w, req)
+					return
+				}
+				http.NotFound(w, req)
+				return
+			}
+		}
+		h.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,70 @@
+package corekit
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/t-ksn/core-kit/apierror"
+)
+
+// AllowedHosts rejects requests whose Host header is not one of hosts with
+// apierror.MisdirectedRequestErr (421), protecting links and cache keys built
+// from the host against spoofing. Hosts are compared case-insensitively,
+// without port or trailing dot; "*.example.com" matches any subdomain of
+// example.com but not example.com itself. Requests to exemptPaths, e.g.
+// "/health" for probes addressing the pod IP, are let through. r.Host is set
+// to its canonical form for the next handlers.
+func AllowedHosts(hosts []string, exemptPaths ...string) Middleware {
+	allowed := make([]string, len(hosts))
+	for i, h := range hosts {
+		allowed[i] = canonicalHost(h)
+	}
+	exempt := map[string]bool{}
+	for _, p := range exemptPaths {
+		exempt[p] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exempt[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			host := canonicalHost(r.Host)
+			if host == "" || !hostAllowed(allowed, host) {
+				WriteError(w, r, apierror.MisdirectedRequestErr)
+				return
+			}
+			if _, port, err := net.SplitHostPort(r.Host); err == nil {
+				r.Host = net.JoinHostPort(host, port)
+			} else if strings.Contains(host, ":") {
+				r.Host = "[" + host + "]"
+			} else {
+				r.Host = host
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// canonicalHost lowercases host and strips its port and trailing dot.
+func canonicalHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimPrefix(strings.TrimSuffix(host, "]"), "[")
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+func hostAllowed(allowed []string, host string) bool {
+	for _, a := range allowed {
+		if a == host {
+			return true
+		}
+		if strings.HasPrefix(a, "*.") && strings.HasSuffix(host, a[1:]) && len(host) > len(a)-1 {
+			return true
+		}
+	}
+	return false
+}
@@ -6,9 +6,10 @@ import (
 )
 
 type APIError struct {
-	Code       int    `json:"code"`
-	Message    string `json:"message"`
-	StatusCode int    `json:"-"`
+	Code       int         `json:"code"`
+	Message    string      `json:"message"`
+	Details    interface{} `json:"details,omitempty"`
+	StatusCode int         `json:"-"`
 }
 
 func (err APIError) Error() string {
@@ -42,4 +43,93 @@ var (
 		StatusCode: http.StatusBadRequest,
 		Message:    "JSON specified as a request is invalid",
 	}
+
+	// STATUS CODE: 400
+	QueryParamInvalidErr = APIError{
+		Code:       10001,
+		StatusCode: http.StatusBadRequest,
+		Message:    "Query parameter is invalid",
+	}
+
+	// STATUS CODE: 422
+	SchemaViolationErr = APIError{
+		Code:       10002,
+		StatusCode: http.StatusUnprocessableEntity,
+		Message:    "Request body does not match the schema",
+	}
+
+	// STATUS CODE: 415
+	UnsupportedMediaTypeErr = APIError{
+		Code:       10003,
+		StatusCode: http.StatusUnsupportedMediaType,
+		Message:    "Request content type is not supported",
+	}
+
+	// STATUS CODE: 400
+	BodyInvalidErr = APIError{
+		Code:       10004,
+		StatusCode: http.StatusBadRequest,
+		Message:    "Request body is invalid",
+	}
+
+	// STATUS CODE: 431
+	HeaderFieldsTooLargeErr = APIError{
+		Code:       10005,
+		StatusCode: http.StatusRequestHeaderFieldsTooLarge,
+		Message:    "Request header field is too large",
+	}
+
+	// STATUS CODE: 413
+	BodyTooLargeErr = APIError{
+		Code:       10006,
+		StatusCode: http.StatusRequestEntityTooLarge,
+		Message:    "Request body is too large",
+	}
+
+	// STATUS CODE: 504
+	GatewayTimeoutErr = APIError{
+		Code:       10007,
+		StatusCode: http.StatusGatewayTimeout,
+		Message:    "Request did not complete in time",
+	}
+
+	// STATUS CODE: 502
+	BadGatewayErr = APIError{
+		Code:       10008,
+		StatusCode: http.StatusBadGateway,
+		Message:    "Upstream service is unavailable",
+	}
+
+	// STATUS CODE: 421
+	MisdirectedRequestErr = APIError{
+		Code:       10009,
+		StatusCode: http.StatusMisdirectedRequest,
+		Message:    "Host is not served by this service",
+	}
+
+	// STATUS CODE: 406
+	NotAcceptableErr = APIError{
+		Code:       10010,
+		StatusCode: http.StatusNotAcceptable,
+		Message:    "Requested media type is not available",
+	}
+
+	// STATUS CODE: 422
+	IdempotencyKeyReusedErr = APIError{
+		Code:       10011,
+		StatusCode: http.StatusUnprocessableEntity,
+		Message:    "Idempotency key was already used for a different request",
+	}
 )
+
+// WithDetails returns a copy of err carrying details, e.g. a list of violations.
+func (err APIError) WithDetails(details interface{}) APIError {
+	err.Details = details
+	return err
+}
+
+// WithMessage returns a copy of err with a more specific message.
+func (err APIError) WithMessage(msg string) APIError {
+	err.Message = msg
+	return err
+}
@@ -0,0 +1,37 @@
+package apierror
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type     string      `json:"type,omitempty"`
+	Title    string      `json:"title,omitempty"`
+	Status   int         `json:"status,omitempty"`
+	Detail   string      `json:"detail,omitempty"`
+	Instance string      `json:"instance,omitempty"`
+	Code     int         `json:"code,omitempty"`
+	Details  interface{} `json:"details,omitempty"`
+}
+
+const ProblemContentType = "application/problem+json"
+
+// ToProblem maps err to problem details. The type is left as "about:blank"
+// unless err carries an application code.
+func ToProblem(err APIError, instance string) Problem {
+	p := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(err.StatusCode),
+		Status:   err.StatusCode,
+		Detail:   err.Message,
+		Instance: instance,
+		Code:     err.Code,
+		Details:  err.Details,
+	}
+	if err.Code != 0 {
+		p.Type = fmt.Sprint("urn:problem:", err.Code)
+	}
+	return p
+}
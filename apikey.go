@@ -0,0 +1,69 @@
+package corekit
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/t-ksn/core-kit/apierror"
+)
+
+// KeyStore resolves API keys to the identity they were issued to. Stores
+// backed by a database should look keys up by a hash rather than in clear.
+type KeyStore interface {
+	Lookup(ctx context.Context, key string) (Identity, bool, error)
+}
+
+// StaticKeys is a KeyStore for keys known at startup, e.g. from configuration.
+type StaticKeys map[string]Identity
+
+// Lookup compares key with every known key in constant time.
+func (s StaticKeys) Lookup(ctx context.Context, key string) (Identity, bool, error) {
+	var found Identity
+	ok := 0
+	for k, id := range s {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			found, ok = id, 1
+		}
+	}
+	return found, ok == 1, nil
+}
+
+// APIKeyOptions configures APIKeyAuth.
+type APIKeyOptions struct {
+	// Header carrying the key. Defaults to "X-API-Key".
+	Header string
+	// QueryParam, when set, is read if the header is missing.
+	QueryParam string
+}
+
+// APIKeyAuth authenticates requests by API key, storing the identity found in
+// store with WithIdentity. Missing or unknown keys are rejected with 401.
+func APIKeyAuth(store KeyStore, opts APIKeyOptions) Middleware {
+	if opts.Header == "" {
+		opts.Header = "X-API-Key"
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(opts.Header)
+			if key == "" && opts.QueryParam != "" {
+				key = r.URL.Query().Get(opts.QueryParam)
+			}
+			if key == "" {
+				WriteError(w, r, apierror.UnauthorizedRequestErr)
+				return
+			}
+
+			id, ok, err := store.Lookup(r.Context(), key)
+			if err != nil {
+				WriteError(w, r, err)
+				return
+			}
+			if !ok {
+				WriteError(w, r, apierror.UnauthorizedRequestErr)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+		})
+	}
+}
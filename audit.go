@@ -0,0 +1,87 @@
+package corekit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AuditEvent is one line written by AuditLog.
+type AuditEvent struct {
+	Time       time.Time `json:"time"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Subject    string    `json:"subject,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	ResourceID string    `json:"resource_id,omitempty"`
+	Status     int       `json:"status"`
+	RemoteAddr string    `json:"remote_addr"`
+	DurationMS float64   `json:"duration_ms"`
+}
+
+// AuditOptions configures AuditLog.
+type AuditOptions struct {
+	// ResourceParam is the route parameter identifying the resource, e.g. "id"
+	// for "/users/:id". Defaults to "id".
+	ResourceParam string
+	// Methods are the audited methods. Defaults to POST, PUT, PATCH and DELETE.
+	Methods []string
+}
+
+// AuditLog writes an AuditEvent to sink, as one line of NDJSON, once each
+// mutating request is served. The caller is the Identity stored by the
+// authentication middleware with WithIdentity, whether it runs before or after
+// AuditLog. Keep sink separate from the access logs, e.g. a dedicated file.
+func AuditLog(sink io.Writer, opts AuditOptions) Middleware {
+	if opts.ResourceParam == "" {
+		opts.ResourceParam = "id"
+	}
+	if opts.Methods == nil {
+		opts.Methods = []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	}
+	audited := map[string]bool{}
+	for _, m := range opts.Methods {
+		audited[m] = true
+	}
+	var mu sync.Mutex
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !audited[r.Method] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			id, _ := IdentityFromContext(r.Context())
+			r = r.WithContext(context.WithValue(r.Context(), identitySinkKey{}, &id))
+			rec, ok := w.(*statusRecorder)
+			if !ok {
+				rec = newStatusRecorder(w)
+			}
+			start := time.Now()
+			next.ServeHTTP(rec, r)
+
+			event := AuditEvent{
+				Time:       start.UTC(),
+				RequestID:  RequestID(r.Context()),
+				Subject:    id.Subject,
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				ResourceID: r.URL.Query().Get(":" + opts.ResourceParam),
+				Status:     rec.metricStatus(),
+				RemoteAddr: r.RemoteAddr,
+				DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			line, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			sink.Write(append(line, '\n'))
+			mu.Unlock()
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package corekit
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type externalURLKey struct{}
+
+func injectExternalURL(u *url.URL) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), externalURLKey{}, u)))
+		})
+	}
+}
+
+// BaseURL returns the URL the client reached the service at, without trailing
+// slash: the ExternalURL option when set, otherwise the scheme and host of r
+// honouring TrustedProxies.
+func BaseURL(r *http.Request) string {
+	if u, ok := r.Context().Value(externalURLKey{}).(*url.URL); ok {
+		return u.String()
+	}
+	return (&url.URL{Scheme: Scheme(r), Host: Host(r)}).String()
+}
+
+// URLFor returns the absolute URL of path, e.g. for a Location header:
+//
+//	w.Header().Set("Location", corekit.URLFor(r, "/users/"+id))
+func URLFor(r *http.Request, path string) string {
+	return BaseURL(r) + "/" + strings.TrimPrefix(path, "/")
+}
@@ -0,0 +1,175 @@
+package corekit
+
+import (
+	"context"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/t-ksn/core-kit/apierror"
+	"github.com/t-ksn/core-kit/jsoncodec"
+)
+
+// BodyDecoder unmarshals a request body into v.
+type BodyDecoder func(body []byte, v interface{}) error
+
+type decodersKey struct{}
+
+type useNumberKey struct{}
+
+// defaultDecoders are the decoders used by Bind besides the ones registered
+// with the Decoder option. With useNumber, JSON numbers are decoded as
+// json.Number by codecs implementing jsoncodec.NumberUnmarshaler.
+func defaultDecoders(codec jsoncodec.Codec, useNumber bool) map[string]BodyDecoder {
+	decodeJSON := codec.Unmarshal
+	if nu, ok := codec.(jsoncodec.NumberUnmarshaler); ok && useNumber {
+		decodeJSON = nu.UnmarshalUseNumber
+	}
+	return map[string]BodyDecoder{
+		"application/json":                  decodeJSON,
+		"application/xml":                   xml.Unmarshal,
+		"text/xml":                          xml.Unmarshal,
+		"application/x-www-form-urlencoded": decodeForm,
+	}
+}
+
+func injectDecoders(decoders map[string]BodyDecoder, useNumber bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), decodersKey{}, decoders)
+			if useNumber {
+				ctx = context.WithValue(ctx, useNumberKey{}, true)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Bind decodes the request body into v with the decoder registered for its
+// Content-Type; a request without Content-Type is decoded as JSON. It fails
+// with apierror.UnsupportedMediaTypeErr for other content types, and with
+// apierror.JSONInvalidErr or apierror.BodyInvalidErr for malformed bodies and
+// apierror.BodyTooLargeErr past MaxBodyBytes.
+func Bind(r *http.Request, v interface{}) error {
+	decoders, ok := r.Context().Value(decodersKey{}).(map[string]BodyDecoder)
+	if !ok {
+		decoders = defaultDecoders(jsoncodec.Std{}, false)
+	}
+
+	contentType := "application/json"
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mt, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			return apierror.UnsupportedMediaTypeErr
+		}
+		contentType = mt
+	}
+	decode, ok := decoders[contentType]
+	if !ok {
+		return apierror.UnsupportedMediaTypeErr
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	if err := decode(body, v); err != nil {
+		if contentType == "application/json" {
+			return apierror.JSONInvalidErr
+		}
+		return apierror.BodyInvalidErr
+	}
+	return nil
+}
+
+// decodeForm decodes an URL-encoded form into *url.Values, map[string]string
+// or a struct whose fields are named by their `form` tag, or by the field name
+// otherwise. Struct fields may be strings, numbers, booleans or string slices.
+func decodeForm(body []byte, v interface{}) error {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return err
+	}
+	switch dst := v.(type) {
+	case *url.Values:
+		*dst = values
+		return nil
+	case *map[string]string:
+		*dst = make(map[string]string, len(values))
+		for k := range values {
+			(*dst)[k] = values.Get(k)
+		}
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("form: cannot decode into %T", v)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Tag.Get("form")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		vals, ok := values[name]
+		if !ok {
+			continue
+		}
+		if err := setFormField(rv.Field(i), vals); err != nil {
+			return errors.Wrapf(err, "form: field %q", name)
+		}
+	}
+	return nil
+}
+
+func setFormField(f reflect.Value, vals []string) error {
+	if f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.String {
+		f.Set(reflect.ValueOf(append([]string(nil), vals...)).Convert(f.Type()))
+		return nil
+	}
+	s := vals[0]
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	default:
+		return errors.Errorf("unsupported type %s", f.Type())
+	}
+	return nil
+}
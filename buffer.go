@@ -0,0 +1,129 @@
+package corekit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+type bufferKey struct{}
+
+// BufferResponse holds the response in memory until the handler returns, so
+// an error reported with WriteError after part of the body was written still
+// produces a proper error status. Responses growing past maxBytes, flushed or
+// hijacked responses (streams) are passed through unbuffered from that point.
+// Best used per route, e.g. through Group; BufferAPIResponses buffers every
+// APIHandler instead. maxBytes defaults to 1MB.
+func BufferResponse(maxBytes int) Middleware {
+	if maxBytes <= 0 {
+		maxBytes = 1 << 20
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bw, r := newBufferedWriter(w, r, maxBytes)
+			next.ServeHTTP(bw, r)
+			bw.flush()
+		})
+	}
+}
+
+type bufferedWriter struct {
+	http.ResponseWriter
+	max         int
+	status      int
+	buf         bytes.Buffer
+	passthrough bool
+	// header is the response header as it was before buffering started,
+	// restored by discard.
+	header http.Header
+}
+
+// newBufferedWriter buffers up to max bytes written to w, returning r with
+// the writer attached for WriteError.
+func newBufferedWriter(w http.ResponseWriter, r *http.Request, max int) (*bufferedWriter, *http.Request) {
+	bw := &bufferedWriter{ResponseWriter: w, max: max, header: w.Header().Clone()}
+	return bw, r.WithContext(context.WithValue(r.Context(), bufferKey{}, bw))
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	if !w.passthrough && w.buf.Len()+len(b) > w.max {
+		w.flush()
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+// flush sends what was buffered and switches to pass-through mode.
+func (w *bufferedWriter) flush() {
+	if w.passthrough {
+		return
+	}
+	w.passthrough = true
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.buf.Len() > 0 {
+		w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+}
+
+// discard drops the buffered response, headers included, so an error can be
+// written instead. It reports false when part of the response has already
+// been sent.
+func (w *bufferedWriter) discard() bool {
+	if w.passthrough {
+		return false
+	}
+	w.status = 0
+	w.buf.Reset()
+	h := w.Header()
+	for k := range h {
+		delete(h, k)
+	}
+	for k, v := range w.header {
+		h[k] = v
+	}
+	return true
+}
+
+func (w *bufferedWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *bufferedWriter) Flush() {
+	w.flush()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *bufferedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("corekit: response writer does not support hijacking")
+	}
+	w.passthrough = true
+	return h.Hijack()
+}
+
+// discardBuffered drops a response buffered by BufferResponse for r, if any.
+func discardBuffered(r *http.Request) {
+	if bw, ok := r.Context().Value(bufferKey{}).(*bufferedWriter); ok {
+		bw.discard()
+	}
+}
@@ -0,0 +1,66 @@
+package corekit
+
+import (
+	"crypto/tls"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// CertificateSet selects the server certificate by the host name the client
+// asks for (SNI), to serve several host names from one service. Names may use
+// a leading wildcard label ("*.example.com"). Certificates can be replaced
+// while serving; new connections pick them up.
+type CertificateSet struct {
+	mu    sync.RWMutex
+	certs map[string]*tls.Certificate
+	def   *tls.Certificate
+}
+
+func NewCertificateSet() *CertificateSet {
+	return &CertificateSet{certs: map[string]*tls.Certificate{}}
+}
+
+// Load reads a PEM certificate and key pair and serves it for hosts.
+func (s *CertificateSet) Load(certFile, keyFile string, hosts ...string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return errors.Wrapf(err, "CertificateSet.Load [%s]", certFile)
+	}
+	s.Add(&cert, hosts...)
+	return nil
+}
+
+// Add serves cert for hosts. The first certificate added is the default one
+// for clients without SNI or asking for an unknown name.
+func (s *CertificateSet) Add(cert *tls.Certificate, hosts ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, h := range hosts {
+		s.certs[strings.ToLower(h)] = cert
+	}
+	if s.def == nil {
+		s.def = cert
+	}
+}
+
+// GetCertificate is meant for tls.Config.GetCertificate.
+func (s *CertificateSet) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if cert, ok := s.certs[name]; ok {
+		return cert, nil
+	}
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		if cert, ok := s.certs["*"+name[i:]]; ok {
+			return cert, nil
+		}
+	}
+	if s.def == nil {
+		return nil, errors.Errorf("corekit: no certificate for %q", hello.ServerName)
+	}
+	return s.def, nil
+}
@@ -0,0 +1,186 @@
+package corekit
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type CompressOptions struct {
+	// MinSize is the smallest body worth compressing. Defaults to 1KB.
+	MinSize int
+	// Level is the gzip level. Defaults to gzip.DefaultCompression.
+	Level int
+	// SkipContentTypes are never compressed. Defaults to text/event-stream and
+	// application/x-ndjson, which must reach the client as soon as they are flushed.
+	SkipContentTypes []string
+}
+
+type compressStateKey struct{}
+
+type compressState struct {
+	disabled bool
+}
+
+// DisableCompression keeps the Compress middleware from compressing the
+// response to r. Streaming handlers call it before writing.
+func DisableCompression(r *http.Request) {
+	if st, ok := r.Context().Value(compressStateKey{}).(*compressState); ok {
+		st.disabled = true
+	}
+}
+
+// Compress gzips responses for clients accepting it. The body is buffered up
+// to MinSize before deciding, so small responses are sent as is. A Flush
+// before that point is taken as a sign of streaming and disables compression.
+func Compress(opts CompressOptions) Middleware {
+	if opts.MinSize <= 0 {
+		opts.MinSize = 1 << 10
+	}
+	if opts.Level == 0 {
+		opts.Level = gzip.DefaultCompression
+	}
+	if opts.SkipContentTypes == nil {
+		opts.SkipContentTypes = []string{"text/event-stream", "application/x-ndjson"}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			st := &compressState{}
+			r = r.WithContext(context.WithValue(r.Context(), compressStateKey{}, st))
+			cw := &compressWriter{ResponseWriter: w, opts: &opts, state: st, status: http.StatusOK}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		if strings.TrimSpace(strings.SplitN(enc, ";", 2)[0]) == "gzip" {
+			return true
+		}
+	}
+	return false
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	opts  *CompressOptions
+	state *compressState
+
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided {
+		return
+	}
+	if code < 200 {
+		w.ResponseWriter.WriteHeader(code) // informational, the final status follows
+		return
+	}
+	w.status = code
+	if code == http.StatusNoContent || code == http.StatusNotModified {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.opts.MinSize {
+			return len(b), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decide sends the headers and the buffered body, compressed when allowed.
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	h := w.Header()
+	if compress && w.compressible() {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		w.gz, _ = gzip.NewWriterLevel(w.ResponseWriter, w.opts.Level)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.gz != nil {
+		_, err = w.gz.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+func (w *compressWriter) compressible() bool {
+	if w.state.disabled || w.Header().Get("Content-Encoding") != "" {
+		return false
+	}
+	ct := w.Header().Get("Content-Type")
+	for _, skip := range w.opts.SkipContentTypes {
+		if strings.HasPrefix(ct, skip) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("corekit: response writer does not support hijacking")
+	}
+	w.decided = true
+	return h.Hijack()
+}
+
+func (w *compressWriter) close() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.gz != nil {
+		w.gz.Close()
+	}
+}
@@ -0,0 +1,46 @@
+package corekit
+
+import (
+	"net"
+	"net/http"
+	"sync"
+)
+
+// connTracker keeps the set of open connections by following http.Server.ConnState.
+// When set, gauge is given the change of the number of connections per state.
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[net.Conn]http.ConnState
+	gauge func(state string, delta float64)
+}
+
+func (t *connTracker) track(c net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.conns == nil {
+		t.conns = map[net.Conn]http.ConnState{}
+	}
+	prev, known := t.conns[c]
+	switch state {
+	case http.StateClosed, http.StateHijacked:
+		delete(t.conns, c)
+	default:
+		t.conns[c] = state
+	}
+	if t.gauge == nil {
+		return
+	}
+	if known {
+		t.gauge(prev.String(), -1)
+	}
+	if _, open := t.conns[c]; open {
+		t.gauge(state.String(), 1)
+	}
+}
+
+func (t *connTracker) open() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.conns)
+}
@@ -0,0 +1,23 @@
+package corekit
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/t-ksn/core-kit/httpclient"
+)
+
+func propagateDeadline(header string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if v := r.Header.Get(header); v != "" {
+				if timeout, err := httpclient.DecodeTimeout(v); err == nil {
+					ctx, cancel := context.WithTimeout(r.Context(), timeout)
+					defer cancel()
+					r = r.WithContext(ctx)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
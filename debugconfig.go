@@ -0,0 +1,146 @@
+package corekit
+
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// debugConfig is the effective configuration served on /debug/config. File
+// paths, secrets and parameter values are left out.
+type debugConfig struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+	Port    int    `json:"port"`
+	HTTPS   bool   `json:"https"`
+	// TLSSource tells where certificates come from: files, provider,
+	// certificate set or tls config.
+	TLSSource string `json:"tls_source,omitempty"`
+
+	ReadTimeout     string `json:"read_timeout"`
+	WriteTimeout    string `json:"write_timeout"`
+	IdleTimeout     string `json:"idle_timeout"`
+	ShutdownTimeout string `json:"shutdown_timeout"`
+	ShutdownDelay   string `json:"shutdown_delay"`
+	HealthTimeout   string `json:"health_timeout"`
+	HealthCacheTTL  string `json:"health_cache_ttl"`
+	SelfCheck       string `json:"self_check,omitempty"`
+	SlowRequest     string `json:"slow_request,omitempty"`
+
+	MaxHeaderBytes  int   `json:"max_header_bytes,omitempty"`
+	MaxURLLength    int   `json:"max_url_length,omitempty"`
+	MaxHeaderLength int   `json:"max_header_length,omitempty"`
+	MaxBodyBytes    int64 `json:"max_body_bytes,omitempty"`
+	StreamOver      int   `json:"stream_responses_over,omitempty"`
+
+	RequestIDs     bool     `json:"request_ids"`
+	DeadlineHeader string   `json:"deadline_header,omitempty"`
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+	ExternalURL    string   `json:"external_url,omitempty"`
+	ProxyProtocol  bool     `json:"proxy_protocol"`
+	ProblemDetails bool     `json:"problem_details"`
+	MetricLabels   []string `json:"metric_labels,omitempty"`
+	Decoders       []string `json:"decoders"`
+	UseNumber      bool     `json:"use_number"`
+	Params         []string `json:"params,omitempty"`
+	HealthChecks   []string `json:"health_checks,omitempty"`
+
+	Middlewares []string `json:"middlewares"`
+	Routes      []string `json:"routes"`
+}
+
+// debugConfigHandler serves the configuration of s, read on every request so
+// routes registered late are listed.
+func debugConfigHandler(s *service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		o := &s.options
+		c := debugConfig{
+			Name:            o.name,
+			Version:         o.version,
+			Port:            o.port,
+			HTTPS:           o.httpsEnabled,
+			ReadTimeout:     o.readTimeout.String(),
+			WriteTimeout:    o.writeTimeout.String(),
+			IdleTimeout:     o.idleTimeout.String(),
+			ShutdownTimeout: o.shutdownTimeout.String(),
+			ShutdownDelay:   o.shutdownDelay.String(),
+			HealthTimeout:   o.healthTimeout.String(),
+			HealthCacheTTL:  o.healthCacheTTL.String(),
+			MaxHeaderBytes:  o.maxHeaderBytes,
+			MaxURLLength:    o.maxURLLength,
+			MaxHeaderLength: o.maxHeaderLength,
+			MaxBodyBytes:    o.maxBodyBytes,
+			StreamOver:      o.streamOver,
+			RequestIDs:      o.requestIDs,
+			DeadlineHeader:  o.deadlineHeader,
+			ProxyProtocol:   o.proxyProtocol,
+			ProblemDetails:  o.problemDetails,
+			MetricLabels:    o.metricLabels,
+			Decoders:        sortedKeys(o.decoders),
+			UseNumber:       o.useNumber,
+			Params:          sortedKeys(o.params),
+			HealthChecks:    sortedKeys(o.healthChecks),
+		}
+		switch {
+		case o.certSet != nil:
+			c.TLSSource = "certificate set"
+		case o.certProvider != nil:
+			c.TLSSource = "provider"
+		case o.certFile != "":
+			c.TLSSource = "files"
+		case o.tlsConfig != nil:
+			c.TLSSource = "tls config"
+		}
+		if o.selfCheck > 0 {
+			c.SelfCheck = o.selfCheck.String()
+		}
+		if o.slowRequest > 0 {
+			c.SlowRequest = o.slowRequest.String()
+		}
+		for _, n := range o.trustedProxies {
+			c.TrustedProxies = append(c.TrustedProxies, n.String())
+		}
+		if o.externalURL != nil {
+			c.ExternalURL = o.externalURL.String()
+		}
+		for _, mw := range o.middlewares {
+			c.Middlewares = append(c.Middlewares, funcName(mw))
+		}
+
+		s.mu.Lock()
+		c.Routes = append([]string(nil), s.routes...)
+		s.mu.Unlock()
+
+		b, _ := o.jsonCodec.Marshal(c)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(append(b, '\n'))
+	})
+}
+
+// funcName names a middleware after the function that created it, e.g.
+// "corekit.Compress".
+func funcName(mw Middleware) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(mw).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	name := fn.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if strings.HasSuffix(name, ".defaultMiddlewaresMarker") {
+		return "DefaultMiddlewares"
+	}
+	return strings.TrimSuffix(name, ".func1")
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
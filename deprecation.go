@@ -0,0 +1,52 @@
+package corekit
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Deprecated marks the routes it wraps as deprecated, e.g.
+//
+//	svc.Group("/v1", corekit.Deprecated(sunset)).Get("/users", listUsers)
+//
+// Responses carry the Deprecation header and, unless sunset is zero, the
+// RFC 8594 Sunset header with the date the routes will be removed. Calls are
+// counted in http_deprecated_requests_total by method and route, and the first
+// call of each route is logged as a warning. It is meant for groups: routes
+// are only known below the mux.
+func Deprecated(sunset time.Time) Middleware {
+	var (
+		count = registerCounter(log.Printf, "http_deprecated_requests_total",
+			"Number of calls to deprecated routes by method and route.", []string{"method", "path"})
+		logged sync.Map
+	)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "true")
+			if !sunset.IsZero() {
+				w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+
+			// Outside of a route, e.g. under Use, the raw path would make the
+			// metric unbounded.
+			route := routePattern(r)
+			if route == "" {
+				route = "other"
+			}
+			count(r.Method, route)
+			if _, seen := logged.LoadOrStore(r.Method+" "+route, true); !seen {
+				serviceLog(r)("[WARN] Deprecated route called: %s %s (sunset %v)\n", r.Method, route, sunsetString(sunset))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func sunsetString(sunset time.Time) string {
+	if sunset.IsZero() {
+		return "not set"
+	}
+	return sunset.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,49 @@
+package corekit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"syscall"
+)
+
+// statusClientClosedRequest labels requests abandoned by the client in metrics
+// (the nginx convention); it is never sent on the wire.
+const statusClientClosedRequest = 499
+
+// isClientDisconnect reports whether err was caused by the client going away
+// rather than by the server.
+func isClientDisconnect(r *http.Request, err error) bool {
+	switch {
+	case errors.Is(err, http.ErrAbortHandler),
+		errors.Is(err, syscall.EPIPE),
+		errors.Is(err, syscall.ECONNRESET):
+		return true
+	case errors.Is(err, context.Canceled):
+		return r.Context().Err() == context.Canceled
+	}
+	return false
+}
+
+// markDisconnected flags the request as abandoned by the client for the
+// metrics recorder under w, if any.
+func markDisconnected(w http.ResponseWriter) {
+	if rec, ok := findRecorder(w); ok {
+		rec.disconnected = true
+	}
+}
+
+// skipIfDisconnected does not call h for requests whose client already went
+// away, e.g. a scraper that gave up while the request was queued. The check is
+// made once, before h starts: promhttp gathers the metrics without looking at
+// the request context, so a client leaving during the gathering is only
+// noticed when the response is written.
+func skipIfDisconnected(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Context().Err() != nil {
+			markDisconnected(w)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,63 @@
+package corekit
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// FromEnv reads the service configuration from environment variables named
+// prefix followed by PORT, NAME, VERSION, CERT_FILE and KEY_FILE (both needed
+// to enable HTTPS), READ_TIMEOUT, WRITE_TIMEOUT, IDLE_TIMEOUT, SHUTDOWN_TIMEOUT
+// and SHUTDOWN_DELAY (Go durations, e.g. "10s"). Options passed explicitly to
+// NewService take precedence regardless of their position.
+func FromEnv(prefix string) Option {
+	return func(o *Options) {
+		o.envPrefix = &prefix
+	}
+}
+
+// applyEnv sets the fields of o still holding their default from the
+// environment variables named prefix followed by the field name, o having been
+// configured by the options passed explicitly to NewService.
+func applyEnv(o *Options, prefix string) {
+	def := newOptions()
+	env := func(name string) (string, bool) {
+		v, ok := os.LookupEnv(prefix + name)
+		return v, ok && v != ""
+	}
+	duration := func(name string, d *time.Duration, dflt time.Duration) {
+		if v, ok := env(name); ok && *d == dflt {
+			parsed, err := time.ParseDuration(v)
+			if err != nil {
+				panic(fmt.Sprintf("corekit: invalid %s%s: %v", prefix, name, err))
+			}
+			*d = parsed
+		}
+	}
+
+	if v, ok := env("PORT"); ok && o.port == def.port {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			panic(fmt.Sprintf("corekit: invalid %sPORT: %v", prefix, err))
+		}
+		o.port = port
+	}
+	if v, ok := env("NAME"); ok && o.name == def.name {
+		o.name = v
+	}
+	if v, ok := env("VERSION"); ok && o.version == def.version {
+		o.version = v
+	}
+	cert, certOK := env("CERT_FILE")
+	key, keyOK := env("KEY_FILE")
+	if certOK && keyOK && !o.httpsEnabled {
+		Https(cert, key)(o)
+	}
+	duration("READ_TIMEOUT", &o.readTimeout, def.readTimeout)
+	duration("WRITE_TIMEOUT", &o.writeTimeout, def.writeTimeout)
+	duration("IDLE_TIMEOUT", &o.idleTimeout, def.idleTimeout)
+	duration("SHUTDOWN_TIMEOUT", &o.shutdownTimeout, def.shutdownTimeout)
+	duration("SHUTDOWN_DELAY", &o.shutdownDelay, def.shutdownDelay)
+}
@@ -0,0 +1,118 @@
+package corekit
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/t-ksn/core-kit/apierror"
+	"github.com/t-ksn/core-kit/jsoncodec"
+)
+
+type ProblemMapper func(r *http.Request, err apierror.APIError) apierror.Problem
+
+// ErrorMapper translates errors that are not an apierror.APIError, e.g. errors
+// of a backend client library. It reports false for errors it does not know.
+type ErrorMapper func(err error) (apierror.APIError, bool)
+
+// errorWriter writes apiErr, encoding any body with codec, the JSONCodec of
+// the service.
+type errorWriter func(w http.ResponseWriter, r *http.Request, codec jsoncodec.Codec, apiErr apierror.APIError)
+
+type errorHandler struct {
+	log     func(format string, args ...interface{})
+	mappers []ErrorMapper
+	write   errorWriter
+	codec   jsoncodec.Codec
+}
+
+type errorHandlerKey struct{}
+
+func (h errorHandler) inject(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), errorHandlerKey{}, h)))
+	})
+}
+
+// serviceLog returns the logger of the service serving r, for middlewares
+// created without access to the options.
+func serviceLog(r *http.Request) func(format string, args ...interface{}) {
+	if h, ok := r.Context().Value(errorHandlerKey{}).(errorHandler); ok && h.log != nil {
+		return h.log
+	}
+	return log.Printf
+}
+
+// WriteError writes err the same way errors returned by API handlers are
+// written, so middlewares can report errors consistently with the service.
+// Under BufferResponse, whatever was written before is discarded.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	discardBuffered(r)
+	h := requestErrorHandler(r)
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	h.handle(w, r, err)
+}
+
+// requestErrorHandler returns the errorHandler of the service serving r.
+func requestErrorHandler(r *http.Request) errorHandler {
+	if h, ok := r.Context().Value(errorHandlerKey{}).(errorHandler); ok {
+		return h
+	}
+	return errorHandler{log: log.Printf, write: writeAPIError, codec: jsoncodec.Std{}}
+}
+
+func (h errorHandler) handle(w http.ResponseWriter, r *http.Request, err error) {
+	if timedOut(r) {
+		h.write(w, r, h.codec, apierror.GatewayTimeoutErr)
+		return
+	}
+	h.write(w, r, h.codec, h.toAPIError(err))
+}
+
+// toAPIError unwraps err to an APIError, then tries the registered mappers.
+// Any other error is logged and reported as an internal server error.
+func (h errorHandler) toAPIError(err error) apierror.APIError {
+	innerErr := errors.Cause(err)
+	if apiErr, ok := innerErr.(apierror.APIError); ok {
+		return apiErr
+	}
+	for _, m := range h.mappers {
+		if apiErr, ok := m(err); ok {
+			return apiErr
+		}
+	}
+	h.log("[ERROR] API wrapper: %+v", err)
+	return apierror.InternalServerErr
+}
+
+func writeAPIError(w http.ResponseWriter, r *http.Request, codec jsoncodec.Codec, apiErr apierror.APIError) {
+	w.WriteHeader(apiErr.StatusCode)
+
+	if apiErr.StatusCode != http.StatusBadRequest && apiErr.StatusCode != http.StatusUnprocessableEntity {
+		return
+	}
+
+	b, _ := codec.Marshal(apiErr)
+	w.Write(b)
+}
+
+func problemErrorWriter(mapper ProblemMapper) errorWriter {
+	if mapper == nil {
+		mapper = func(r *http.Request, err apierror.APIError) apierror.Problem {
+			return apierror.ToProblem(err, r.URL.Path)
+		}
+	}
+	return func(w http.ResponseWriter, r *http.Request, codec jsoncodec.Codec, apiErr apierror.APIError) {
+		p := mapper(r, apiErr)
+		if p.Status == 0 {
+			p.Status = apiErr.StatusCode
+		}
+		w.Header().Set("Content-Type", apierror.ProblemContentType)
+		w.WriteHeader(p.Status)
+		b, _ := codec.Marshal(p)
+		w.Write(b)
+	}
+}
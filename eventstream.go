@@ -0,0 +1,238 @@
+package corekit
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/t-ksn/core-kit/apierror"
+	"github.com/t-ksn/core-kit/jsoncodec"
+)
+
+const (
+	EventStreamContentType = "text/event-stream"
+	NDJSONContentType      = "application/x-ndjson"
+)
+
+// EventStreamHandler produces a stream of messages with w. An error returned
+// before the first Send is written as a regular error response; once the
+// stream started it is reported to the client with an error frame.
+type EventStreamHandler func(req *http.Request, w *StreamWriter) error
+
+// StreamWriter writes the messages of an event stream as Server-Sent Events
+// when the client accepts text/event-stream and as NDJSON otherwise.
+type StreamWriter struct {
+	w       http.ResponseWriter
+	r       *http.Request
+	codec   jsoncodec.Codec
+	sse     bool
+	started bool
+
+	// mu guards the writes when AutoFlush batches them.
+	mu       sync.Mutex
+	batching bool
+	maxBytes int
+	pending  bytes.Buffer
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+func newStreamWriter(w http.ResponseWriter, r *http.Request, codec jsoncodec.Codec) *StreamWriter {
+	return &StreamWriter{
+		w:     w,
+		r:     r,
+		codec: codec,
+		sse:   strings.Contains(r.Header.Get("Accept"), EventStreamContentType),
+	}
+}
+
+// Send writes v as one message. It is flushed to the client right away unless
+// AutoFlush was called.
+func (s *StreamWriter) Send(v interface{}) error {
+	b, err := s.codec.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "StreamWriter.Send [JSON marshal message]")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.start()
+	if !s.batching {
+		return writeFrame(s.w, s.sse, "", b)
+	}
+	s.pending.Write(frame(s.sse, "", b))
+	if s.pending.Len() >= s.maxBytes {
+		return s.flushPending()
+	}
+	return nil
+}
+
+// AutoFlush batches the messages sent afterwards, flushing them every
+// interval, as soon as maxBytes (4KB when <= 0) are pending, when the request
+// context is done and when the handler returns. An interval <= 0 flushes on
+// size only. It trades latency for fewer, larger writes on busy streams.
+func (s *StreamWriter) AutoFlush(interval time.Duration, maxBytes int) {
+	if maxBytes <= 0 {
+		maxBytes = 4 << 10
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxBytes = maxBytes
+	if s.batching {
+		return
+	}
+	s.batching = true
+	s.stop, s.stopped = make(chan struct{}), make(chan struct{})
+
+	go func() {
+		defer close(s.stopped)
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case <-tick:
+			case <-s.r.Context().Done():
+				s.mu.Lock()
+				s.flushPending()
+				s.mu.Unlock()
+				return
+			case <-s.stop:
+				return
+			}
+			s.mu.Lock()
+			s.flushPending()
+			s.mu.Unlock()
+		}
+	}()
+}
+
+// flushPending writes the batched messages. s.mu must be held.
+func (s *StreamWriter) flushPending() error {
+	if s.pending.Len() == 0 {
+		return nil
+	}
+	_, err := s.w.Write(s.pending.Bytes())
+	s.pending.Reset()
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return err
+}
+
+// close stops AutoFlush and writes what is still pending.
+func (s *StreamWriter) close() {
+	s.mu.Lock()
+	batching := s.batching
+	s.batching = false
+	s.mu.Unlock()
+	if !batching {
+		return
+	}
+	close(s.stop)
+	<-s.stopped
+	s.mu.Lock()
+	s.flushPending()
+	s.mu.Unlock()
+}
+
+func (s *StreamWriter) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	if s.sse {
+		s.w.Header().Set("Content-Type", EventStreamContentType)
+	} else {
+		s.w.Header().Set("Content-Type", NDJSONContentType)
+	}
+	s.w.Header().Set("Cache-Control", "no-cache")
+	s.w.WriteHeader(http.StatusOK)
+}
+
+// StreamError reports err to the client of a stream that already started:
+// an "error" event for Server-Sent Events, or a {"error": {...}} line for
+// NDJSON. err is translated by the ErrorMappers of the service as by
+// WriteError, and the request is counted in metrics with the status of the
+// error instead of the 200 that was already sent.
+func StreamError(w http.ResponseWriter, r *http.Request, err error) {
+	h := requestErrorHandler(r)
+	writeStreamError(w, h.codec, w.Header().Get("Content-Type") == EventStreamContentType, h.toAPIError(err))
+}
+
+func writeStreamError(w http.ResponseWriter, codec jsoncodec.Codec, sse bool, apiErr apierror.APIError) {
+	if rec, ok := findRecorder(w); ok {
+		rec.streamErrStatus = apiErr.StatusCode
+	}
+	frame := streamErrorFrame{APIError: apiErr, Status: apiErr.StatusCode}
+	if sse {
+		b, _ := codec.Marshal(frame)
+		writeFrame(w, true, "error", b)
+		return
+	}
+	b, _ := codec.Marshal(struct {
+		Error streamErrorFrame `json:"error"`
+	}{frame})
+	writeFrame(w, false, "", b)
+}
+
+// streamErrorFrame is the APIError sent in an error frame. The status is
+// included since the response status line was already sent.
+type streamErrorFrame struct {
+	apierror.APIError
+	Status int `json:"status"`
+}
+
+func frame(sse bool, event string, data []byte) []byte {
+	if !sse {
+		return append(data, '\n')
+	}
+	var b []byte
+	if event != "" {
+		b = append(b, "event: "+event+"\n"...)
+	}
+	b = append(b, "data: "...)
+	b = append(b, data...)
+	return append(b, "\n\n"...)
+}
+
+func writeFrame(w http.ResponseWriter, sse bool, event string, data []byte) error {
+	if _, err := w.Write(frame(sse, event, data)); err != nil {
+		return err
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+func eventStreamWrapAPIHandler(errs errorHandler, codec jsoncodec.Codec) func(handler EventStreamHandler) http.Handler {
+	return func(handler EventStreamHandler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			DisableCompression(r)
+			sw := newStreamWriter(w, r, codec)
+
+			err := handler(r, sw)
+			sw.close()
+			if err == nil {
+				return
+			}
+			if isClientDisconnect(r, err) {
+				markDisconnected(w)
+				return
+			}
+			if !sw.started {
+				w.Header().Set("Content-Type", "application/json")
+				errs.handle(w, r, err)
+				return
+			}
+			writeStreamError(w, errs.codec, sw.sse, errs.toAPIError(err))
+		})
+	}
+}
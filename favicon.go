@@ -0,0 +1,22 @@
+package corekit
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+)
+
+// faviconHandler serves icon, or 204 No Content when it is empty. It is not
+// instrumented so browser requests do not show up in metrics.
+func faviconHandler(icon []byte) http.Handler {
+	modTime := time.Now()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(icon) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "image/x-icon")
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		http.ServeContent(w, r, "favicon.ico", modTime, bytes.NewReader(icon))
+	})
+}
@@ -0,0 +1,147 @@
+package corekit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type forwardedKey struct{}
+
+// forwarded is what trusted proxies told us about the original request.
+type forwarded struct {
+	clientIP string
+	proto    string
+	host     string
+}
+
+// trustProxies parses Forwarded (RFC 7239) and X-Forwarded-* headers, but only
+// when the request comes from one of the trusted networks. The hops are walked
+// from the nearest proxy outwards and the first untrusted hop is taken as the
+// client, so values injected by the client itself are ignored.
+func trustProxies(trusted []*net.IPNet) Middleware {
+	isTrusted := func(addr string) bool {
+		ip := net.ParseIP(stripPort(addr))
+		if ip == nil {
+			return false
+		}
+		for _, n := range trusted {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isTrusted(r.RemoteAddr) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			hops := parseForwarded(r.Header.Values("Forwarded"))
+			if len(hops) == 0 {
+				hops = parseXForwarded(r.Header)
+			}
+
+			var fwd *forwarded
+			for i := len(hops) - 1; i >= 0; i-- {
+				fwd = &hops[i]
+				if !isTrusted(hops[i].clientIP) {
+					break
+				}
+			}
+			if fwd != nil {
+				r = r.WithContext(context.WithValue(r.Context(), forwardedKey{}, fwd))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func parseForwarded(values []string) []forwarded {
+	var hops []forwarded
+	for _, v := range values {
+		for _, elem := range strings.Split(v, ",") {
+			var hop forwarded
+			for _, pair := range strings.Split(elem, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) != 2 {
+					continue
+				}
+				val := strings.Trim(kv[1], `"`)
+				switch strings.ToLower(kv[0]) {
+				case "for":
+					hop.clientIP = stripPort(val)
+				case "proto":
+					hop.proto = strings.ToLower(val)
+				case "host":
+					hop.host = val
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// parseXForwarded maps X-Forwarded-For to hops. X-Forwarded-Proto and
+// X-Forwarded-Host carry a single value set by the nearest proxy, so they are
+// attached to every hop.
+func parseXForwarded(h http.Header) []forwarded {
+	proto := strings.ToLower(strings.TrimSpace(strings.Split(h.Get("X-Forwarded-Proto"), ",")[0]))
+	host := strings.TrimSpace(strings.Split(h.Get("X-Forwarded-Host"), ",")[0])
+
+	var hops []forwarded
+	for _, v := range h.Values("X-Forwarded-For") {
+		for _, ip := range strings.Split(v, ",") {
+			hops = append(hops, forwarded{clientIP: stripPort(strings.TrimSpace(ip)), proto: proto, host: host})
+		}
+	}
+	if len(hops) == 0 && (proto != "" || host != "") {
+		hops = append(hops, forwarded{proto: proto, host: host})
+	}
+	return hops
+}
+
+// stripPort removes the port and IPv6 brackets from addr.
+func stripPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.Trim(addr, "[]")
+}
+
+func forwardedFrom(r *http.Request) *forwarded {
+	fwd, _ := r.Context().Value(forwardedKey{}).(*forwarded)
+	return fwd
+}
+
+// Scheme returns the scheme the client used, honouring headers set by trusted proxies.
+func Scheme(r *http.Request) string {
+	if fwd := forwardedFrom(r); fwd != nil && fwd.proto != "" {
+		return fwd.proto
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// Host returns the host the client requested, honouring headers set by trusted proxies.
+func Host(r *http.Request) string {
+	if fwd := forwardedFrom(r); fwd != nil && fwd.host != "" {
+		return fwd.host
+	}
+	return r.Host
+}
+
+// ClientIP returns the client address, honouring headers set by trusted proxies.
+func ClientIP(r *http.Request) string {
+	if fwd := forwardedFrom(r); fwd != nil && fwd.clientIP != "" {
+		return fwd.clientIP
+	}
+	return stripPort(r.RemoteAddr)
+}
@@ -0,0 +1,60 @@
+package corekit
+
+import (
+	"context"
+	"errors"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Group runs several services in one process and shuts them all down together
+// on SIGINT/SIGTERM. If any service fails, the rest are shut down as well.
+type Group struct {
+	services []Service
+}
+
+func NewGroup(services ...Service) *Group {
+	return &Group{services: services}
+}
+
+func (g *Group) Add(s Service) {
+	g.services = append(g.services, s)
+}
+
+// Run blocks until SIGINT/SIGTERM and every service has stopped. It is the
+// only place that should own signal handling in a multi-service binary.
+func (g *Group) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	return g.RunContext(ctx)
+}
+
+// RunContext runs every service until ctx is done or one of them fails, and
+// returns their aggregated errors.
+func (g *Group) RunContext(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, s := range g.services {
+		wg.Add(1)
+		go func(s Service) {
+			defer wg.Done()
+			if err := s.RunContext(ctx); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+				cancel()
+			}
+		}(s)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,55 @@
+// Package grpcerror maps gRPC status errors to API errors. It is kept apart
+// from corekit so that only services using it depend on grpc:
+//
+//	corekit.NewService(corekit.MapErrors(grpcerror.ToAPIError))
+package grpcerror
+
+import (
+	"net/http"
+
+	"github.com/t-ksn/core-kit/apierror"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// HTTPStatus follows the grpc-gateway mapping of gRPC codes to HTTP status codes.
+func HTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // client closed request
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// ToAPIError is a corekit.ErrorMapper for errors carrying a gRPC status.
+func ToAPIError(err error) (apierror.APIError, bool) {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() == codes.OK {
+		return apierror.APIError{}, false
+	}
+	return apierror.APIError{
+		Code:       int(st.Code()),
+		Message:    st.Message(),
+		StatusCode: HTTPStatus(st.Code()),
+	}, true
+}
@@ -0,0 +1,181 @@
+package corekit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthCheckFunc checks a single dependency. A non-nil error marks the service unhealthy.
+type HealthCheckFunc func(ctx context.Context) error
+
+// HealthReport is the outcome of a round of health checks.
+type HealthReport struct {
+	Healthy bool
+	// Checks holds "ok" or the error message of every check by name.
+	Checks    map[string]string
+	CheckedAt time.Time
+}
+
+// HealthAggregator runs health checks concurrently under a timeout and caches
+// the report, sharing a round in progress between concurrent callers. The
+// service serves the one built from its HealthCheck options on /health; it is
+// independent of HTTP and may also be used alone, e.g. by a CLI health command:
+//
+//	h := corekit.NewHealthAggregator(3*time.Second, 0)
+//	h.Register("db", db.PingContext)
+//	report, err := h.Run(ctx)
+type HealthAggregator struct {
+	timeout time.Duration
+	ttl     time.Duration
+
+	mu       sync.Mutex
+	checks   map[string]HealthCheckFunc
+	gen      int
+	last     *healthCall
+	inflight *healthCall
+}
+
+type healthCall struct {
+	done   chan struct{}
+	report HealthReport
+}
+
+// NewHealthAggregator returns an aggregator bounding each round by timeout and
+// reusing its report for ttl; a zero timeout does not bound the rounds and a
+// zero ttl runs the checks on every call.
+func NewHealthAggregator(timeout, ttl time.Duration) *HealthAggregator {
+	return &HealthAggregator{timeout: timeout, ttl: ttl, checks: map[string]HealthCheckFunc{}}
+}
+
+func newHealthAggregator(o *Options) *HealthAggregator {
+	h := NewHealthAggregator(o.healthTimeout, o.healthCacheTTL)
+	for name, f := range o.healthChecks {
+		h.Register(name, f)
+	}
+	return h
+}
+
+// Register adds check under name, replacing the check already registered
+// under it, if any. The cached report is dropped.
+func (h *HealthAggregator) Register(name string, check HealthCheckFunc) {
+	if check == nil {
+		panic(fmt.Sprintf("corekit: HealthAggregator.Register(%q) with nil check", name))
+	}
+	h.mu.Lock()
+	h.checks[name] = check
+	h.gen++
+	h.last = nil
+	h.inflight = nil
+	h.mu.Unlock()
+}
+
+// Run returns the cached report while it is fresh, otherwise it joins the
+// round of checks in progress or starts a new one. The round itself is not
+// bound to ctx so that a cancelled caller does not fail the report shared by
+// others; Run only fails with the error of ctx when it ends first.
+func (h *HealthAggregator) Run(ctx context.Context) (HealthReport, error) {
+	h.mu.Lock()
+	if h.last != nil && time.Since(h.last.report.CheckedAt) < h.ttl {
+		call := h.last
+		h.mu.Unlock()
+		return call.report, nil
+	}
+	call := h.inflight
+	if call == nil {
+		call = &healthCall{done: make(chan struct{})}
+		h.inflight = call
+		go h.run(call, h.gen, h.copyChecks())
+	}
+	h.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.report, nil
+	case <-ctx.Done():
+		return HealthReport{}, ctx.Err()
+	}
+}
+
+// copyChecks returns the registered checks, h.mu being held.
+func (h *HealthAggregator) copyChecks() map[string]HealthCheckFunc {
+	checks := make(map[string]HealthCheckFunc, len(h.checks))
+	for name, f := range h.checks {
+		checks[name] = f
+	}
+	return checks
+}
+
+func (h *HealthAggregator) len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.checks)
+}
+
+// run runs checks, registered as of generation gen. Its report is not cached
+// when Register was called meanwhile.
+func (h *HealthAggregator) run(call *healthCall, gen int, checks map[string]HealthCheckFunc) {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if h.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+	}
+	defer cancel()
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	report := HealthReport{Healthy: true, Checks: map[string]string{}}
+	for name, f := range checks {
+		wg.Add(1)
+		go func(name string, f HealthCheckFunc) {
+			defer wg.Done()
+			err := f(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				report.Checks[name] = err.Error()
+				report.Healthy = false
+				return
+			}
+			report.Checks[name] = "ok"
+		}(name, f)
+	}
+	wg.Wait()
+	report.CheckedAt = time.Now()
+	call.report = report
+
+	h.mu.Lock()
+	if h.gen == gen {
+		h.last = call
+		h.inflight = nil
+	}
+	h.mu.Unlock()
+	close(call.done)
+}
+
+func healthHandler(h *HealthAggregator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.len() == 0 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		report, err := h.Run(r.Context())
+		if err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("content-type", "application/json")
+		if report.Healthy {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report.Checks)
+	})
+}
@@ -0,0 +1,48 @@
+package corekit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"strings"
+
+	"github.com/t-ksn/core-kit/apierror"
+)
+
+// VerifyHMAC rejects with 401 requests whose header does not hold the hex
+// HMAC of the raw body keyed with secret, as sent by webhook providers. A
+// "<algo>=" prefix such as GitHub's "sha256=" is ignored. algo defaults to
+// sha256.New. The body is left intact for the handler.
+func VerifyHMAC(secret []byte, header string, algo func() hash.Hash) Middleware {
+	if algo == nil {
+		algo = sha256.New
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sig := r.Header.Get(header)
+			if i := strings.IndexByte(sig, '='); i >= 0 {
+				sig = sig[i+1:]
+			}
+			got, err := hex.DecodeString(sig)
+			if sig == "" || err != nil {
+				WriteError(w, r, apierror.UnauthorizedRequestErr)
+				return
+			}
+
+			body, err := RawBody(r)
+			if err != nil {
+				WriteError(w, r, err)
+				return
+			}
+			mac := hmac.New(algo, secret)
+			mac.Write(body)
+			if !hmac.Equal(got, mac.Sum(nil)) {
+				WriteError(w, r, apierror.UnauthorizedRequestErr)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
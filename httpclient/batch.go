@@ -0,0 +1,55 @@
+package httpclient
+
+import (
+	"context"
+	"sync"
+)
+
+// Request describes a single call made by SendBatch. The response is decoded
+// into RespObj as with Send.
+type Request struct {
+	Method  string
+	URL     string
+	Payload interface{}
+	RespObj interface{}
+}
+
+type Result struct {
+	Request Request
+	Err     error
+}
+
+// SendBatch sends the requests concurrently and returns their results in the
+// same order. Requests that have not started when ctx is done fail with ctx.Err().
+func (c *VChatClient) SendBatch(ctx context.Context, reqs []Request) []Result {
+	limit := c.BatchConcurrency
+	if limit <= 0 {
+		limit = 8
+	}
+
+	results := make([]Result, len(reqs))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		results[i].Request = req
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, req Request) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i].Err = c.Send(ctx, req.Method, req.URL, req.Payload, req.RespObj)
+		}(i, req)
+	}
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,189 @@
+package httpclient
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CachedResponse is a GET response stored by CachingDoer.
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	ETag       string
+	Expires    time.Time
+}
+
+// Cache stores responses for CachingDoer. Implementations must be safe for
+// concurrent use.
+type Cache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, resp *CachedResponse)
+}
+
+// MemoryCache is an in-memory Cache holding at most MaxEntries responses
+// (1000 when zero). When full, an arbitrary entry is evicted.
+type MemoryCache struct {
+	MaxEntries int
+
+	mu    sync.Mutex
+	items map[string]*CachedResponse
+}
+
+func (c *MemoryCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resp, ok := c.items[key]
+	return resp, ok
+}
+
+func (c *MemoryCache) Set(key string, resp *CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	max := c.MaxEntries
+	if max <= 0 {
+		max = 1000
+	}
+	if c.items == nil {
+		c.items = map[string]*CachedResponse{}
+	}
+	if _, ok := c.items[key]; !ok && len(c.items) >= max {
+		for k := range c.items {
+			delete(c.items, k)
+			break
+		}
+	}
+	c.items[key] = resp
+}
+
+// CachingDoer caches successful GET responses per URL for the max-age given by
+// their Cache-Control header. Expired entries with an ETag are revalidated with
+// If-None-Match. Responses marked no-store or private, or carrying Vary, are
+// never cached, nor are requests with Authorization or Cookie headers so that
+// one caller's response is not served to another.
+type CachingDoer struct {
+	Client HTTPClient
+	// Cache defaults to a MemoryCache.
+	Cache Cache
+
+	once sync.Once
+}
+
+func (c *CachingDoer) Do(req *http.Request) (*http.Response, error) {
+	c.once.Do(func() {
+		if c.Cache == nil {
+			c.Cache = &MemoryCache{}
+		}
+	})
+	if req.Method != http.MethodGet || hasDirective(req.Header, "no-store") ||
+		req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != "" {
+		return c.getClient().Do(req)
+	}
+
+	key := req.URL.String()
+	cached, ok := c.Cache.Get(key)
+	if ok && time.Now().Before(cached.Expires) && !hasDirective(req.Header, "no-cache") {
+		return cached.response(req), nil
+	}
+	// Only a 304 answering the validator added here is turned into the cached
+	// response; one asked for by the caller is returned as is.
+	revalidating := ok && cached.ETag != "" && req.Header.Get("If-None-Match") == ""
+	if revalidating {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := c.getClient().Do(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && revalidating {
+		resp.Body.Close()
+		refreshed := *cached
+		refreshed.Expires = expiresAt(resp.Header)
+		c.Cache.Set(key, &refreshed)
+		return refreshed.response(req), nil
+	}
+
+	if resp.StatusCode != http.StatusOK || hasDirective(resp.Header, "no-store") ||
+		hasDirective(resp.Header, "private") || resp.Header.Get("Vary") != "" {
+		return resp, nil
+	}
+	etag := resp.Header.Get("ETag")
+	expires := expiresAt(resp.Header)
+	if etag == "" && !time.Now().Before(expires) {
+		return resp, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "CachingDoer.Do [ReadBody]")
+	}
+	entry := &CachedResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+		ETag:       etag,
+		Expires:    expires,
+	}
+	c.Cache.Set(key, entry)
+	return entry.response(req), nil
+}
+
+func (r *CachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        http.StatusText(r.StatusCode),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.Header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
+
+// expiresAt derives the expiry from Cache-Control max-age. no-cache responses
+// expire immediately so they are always revalidated.
+func expiresAt(h http.Header) time.Time {
+	now := time.Now()
+	if hasDirective(h, "no-cache") {
+		return now
+	}
+	for _, d := range strings.Split(h.Get("Cache-Control"), ",") {
+		d = strings.TrimSpace(d)
+		if strings.HasPrefix(d, "max-age=") {
+			if secs, err := strconv.Atoi(strings.TrimPrefix(d, "max-age=")); err == nil && secs > 0 {
+				return now.Add(time.Duration(secs) * time.Second)
+			}
+		}
+	}
+	return now
+}
+
+func hasDirective(h http.Header, directive string) bool {
+	for _, d := range strings.Split(h.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(d), directive) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CachingDoer) getClient() HTTPClient {
+	if c.Client == nil {
+		return http.DefaultClient
+	}
+	return c.Client
+}
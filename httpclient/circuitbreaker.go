@@ -0,0 +1,153 @@
+package httpclient
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CircuitBreakerDoer stops calling the wrapped client after Threshold
+// consecutive failures (transport errors or 5xx responses). While open, requests
+// fail immediately with an error matching ErrCircuitOpen. After Cooldown a
+// single trial request is let through to probe the downstream. State changes
+// are recorded by Name and host in the httpclient_circuit_transitions_total
+// and httpclient_circuit_state metrics.
+type CircuitBreakerDoer struct {
+	Client HTTPClient
+	// Name labels the metrics of the breaker and defaults to "default"; give
+	// each breaker of a process its own.
+	Name string
+	// Threshold defaults to 5 consecutive failures.
+	Threshold int
+	// Cooldown defaults to 10s.
+	Cooldown time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+	lastErr  error
+	// hosts are the hosts served, whose httpclient_circuit_state follows the
+	// state of the breaker.
+	hosts map[string]bool
+}
+
+func (c *CircuitBreakerDoer) Do(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if err := c.allow(host); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.getClient().Do(req)
+	switch {
+	case err != nil:
+		c.failure(host, err)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		c.failure(host, statusError{resp.StatusCode})
+	default:
+		c.success(host)
+	}
+	return resp, err
+}
+
+// state returns the circuit state, c.mu being held.
+func (c *CircuitBreakerDoer) state() string {
+	switch {
+	case c.failures < c.threshold():
+		return circuitClosed
+	case c.trial:
+		return circuitHalfOpen
+	}
+	return circuitOpen
+}
+
+// transition records a change of state since before, c.mu being held.
+func (c *CircuitBreakerDoer) transition(host, before string) {
+	if after := c.state(); after != before {
+		c.record(host, after)
+	}
+}
+
+// record reports a change to state caused by a request to host, c.mu being
+// held.
+func (c *CircuitBreakerDoer) record(host, state string) {
+	m := getMetrics()
+	m.circuitTransition(c.name(), host, state)
+	for h := range c.hosts {
+		m.circuitState(c.name(), h, state)
+	}
+}
+
+func (c *CircuitBreakerDoer) allow(host string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.hosts[host] {
+		if c.hosts == nil {
+			c.hosts = map[string]bool{}
+		}
+		c.hosts[host] = true
+		getMetrics().circuitState(c.name(), host, c.state())
+	}
+
+	if c.failures < c.threshold() {
+		return nil
+	}
+	if c.trial || time.Since(c.openedAt) < c.cooldown() {
+		return &sentinelError{ErrCircuitOpen, c.lastErr}
+	}
+	c.trial = true
+	c.record(host, circuitHalfOpen)
+	return nil
+}
+
+func (c *CircuitBreakerDoer) failure(host string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.transition(host, c.state())
+
+	c.failures++
+	c.lastErr = err
+	c.trial = false
+	if c.failures >= c.threshold() {
+		c.openedAt = time.Now()
+	}
+}
+
+func (c *CircuitBreakerDoer) success(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.transition(host, c.state())
+
+	c.failures = 0
+	c.lastErr = nil
+	c.trial = false
+}
+
+func (c *CircuitBreakerDoer) name() string {
+	if c.Name == "" {
+		return "default"
+	}
+	return c.Name
+}
+
+func (c *CircuitBreakerDoer) threshold() int {
+	if c.Threshold <= 0 {
+		return 5
+	}
+	return c.Threshold
+}
+
+func (c *CircuitBreakerDoer) cooldown() time.Duration {
+	if c.Cooldown <= 0 {
+		return 10 * time.Second
+	}
+	return c.Cooldown
+}
+
+func (c *CircuitBreakerDoer) getClient() HTTPClient {
+	if c.Client == nil {
+		return http.DefaultClient
+	}
+	return c.Client
+}
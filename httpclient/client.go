@@ -3,13 +3,15 @@ package httpclient
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/t-ksn/core-kit/apierror"
+	"github.com/t-ksn/core-kit/jsoncodec"
 )
 
 type HTTPClient interface {
@@ -18,14 +20,28 @@ type HTTPClient interface {
 type VChatClient struct {
 	Client         HTTPClient
 	ServiceAddress string
+	// BatchConcurrency caps concurrent calls made by SendBatch. Defaults to 8.
+	BatchConcurrency int
+	// DeadlineHeader is the header used to pass the context deadline downstream.
+	// Defaults to DefaultDeadlineHeader.
+	DeadlineHeader string
+	// Codec replaces encoding/json for payloads and responses.
+	Codec jsoncodec.Codec
+	// StrictDecoding makes Send fail on response fields respObj does not
+	// declare. Codec must implement jsoncodec.StrictUnmarshaler, as the
+	// default one does, or Send fails without sending the request.
+	StrictDecoding bool
 }
 
 func (c *VChatClient) Send(ctx context.Context, method string, url string, payload interface{}, respObj interface{}) error {
 	var reqBody []byte
 	var err error
 
+	if _, ok := c.codec().(jsoncodec.StrictUnmarshaler); c.StrictDecoding && !ok {
+		return errors.Errorf("VChatClient.Send [StrictDecoding with codec %T not implementing jsoncodec.StrictUnmarshaler]", c.codec())
+	}
 	if payload != nil {
-		reqBody, err = json.Marshal(payload)
+		reqBody, err = c.codec().Marshal(payload)
 		if err != nil {
 			return errors.Wrap(err, "VChatClient.Send [JSON marshal payload]")
 		}
@@ -34,10 +50,17 @@ func (c *VChatClient) Send(ctx context.Context, method string, url string, paylo
 	if err != nil {
 		return errors.Wrapf(err, "VChatClient.Send [Method: %s Path: %s ]", method, url)
 	}
+	req = req.WithContext(ctx)
 	req.Header.Add("content-type", "application/json")
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set(c.deadlineHeader(), EncodeTimeout(time.Until(deadline)))
+	}
 
 	resp, err := c.getHTTPClient().Do(req)
 	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
 		return errors.Wrapf(err, "VChatClient.Send [Send request]")
 	}
 	defer resp.Body.Close()
@@ -51,7 +74,7 @@ func (c *VChatClient) Send(ctx context.Context, method string, url string, paylo
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 { // http status code seccess
 		var verr apierror.APIError
-		err = json.Unmarshal(body, &verr)
+		err = c.codec().Unmarshal(body, &verr)
 		if err != nil {
 			return errors.Wrapf(err, "VChatClient.Send [UnmarshalResponseErr(status code: %v body: %s)]", resp.StatusCode, body)
 		}
@@ -63,13 +86,68 @@ func (c *VChatClient) Send(ctx context.Context, method string, url string, paylo
 		return nil
 	}
 
-	err = json.Unmarshal(body, respObj)
-	if err != nil {
-		return errors.Wrapf(err, "VChatClient.Send [UnmarshalResponseErr(status code: %v body: %s)]", resp.StatusCode, body)
+	if err = c.unmarshalResponse(body, respObj); err != nil {
+		return errors.Wrapf(err, "VChatClient.Send [UnmarshalResponseErr(into %s, status code: %v, %s body: %s)]",
+			strings.TrimPrefix(fmt.Sprintf("%T", respObj), "*"), resp.StatusCode, jsonKind(body), snippet(body))
 	}
 	return nil
 }
 
+func (c *VChatClient) unmarshalResponse(body []byte, respObj interface{}) error {
+	if c.StrictDecoding {
+		return c.codec().(jsoncodec.StrictUnmarshaler).UnmarshalStrict(body, respObj)
+	}
+	return c.codec().Unmarshal(body, respObj)
+}
+
+// snippetBytes is how much of a response body is quoted in errors.
+const snippetBytes = 256
+
+func snippet(body []byte) string {
+	if len(body) <= snippetBytes {
+		return string(body)
+	}
+	return string(body[:snippetBytes]) + "..."
+}
+
+// jsonKind names the kind of JSON value body holds, to tell at a glance an
+// array sent for an object.
+func jsonKind(body []byte) string {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return "empty"
+	}
+	switch body[0] {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case '"':
+		return "string"
+	case 't', 'f':
+		return "boolean"
+	case 'n':
+		return "null"
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return "number"
+	}
+	return "non-JSON"
+}
+
+func (c *VChatClient) codec() jsoncodec.Codec {
+	if c.Codec == nil {
+		return jsoncodec.Std{}
+	}
+	return c.Codec
+}
+
+func (c *VChatClient) deadlineHeader() string {
+	if c.DeadlineHeader == "" {
+		return DefaultDeadlineHeader
+	}
+	return c.DeadlineHeader
+}
+
 func (c *VChatClient) getHTTPClient() HTTPClient {
 	if c.Client == nil {
 		return http.DefaultClient
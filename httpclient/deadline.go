@@ -0,0 +1,62 @@
+package httpclient
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultDeadlineHeader carries the time left until the caller's deadline.
+const DefaultDeadlineHeader = "X-Request-Timeout"
+
+var timeoutUnits = []struct {
+	suffix byte
+	unit   time.Duration
+}{
+	{'H', time.Hour},
+	{'M', time.Minute},
+	{'S', time.Second},
+	{'m', time.Millisecond},
+	{'u', time.Microsecond},
+	{'n', time.Nanosecond},
+}
+
+// EncodeTimeout formats d in the grpc-timeout style: at most 8 digits
+// followed by a unit (H, M, S, m, u, n). The value is rounded up so that the
+// callee never gets more time than the caller has.
+func EncodeTimeout(d time.Duration) string {
+	if d <= 0 {
+		return "0n"
+	}
+	for i := len(timeoutUnits) - 1; i >= 0; i-- {
+		u := timeoutUnits[i]
+		v := (d + u.unit - 1) / u.unit
+		if v < 100000000 {
+			// prefer the coarsest unit that keeps the value exact
+			for j := i - 1; j >= 0 && d%timeoutUnits[j].unit == 0; j-- {
+				u = timeoutUnits[j]
+				v = d / u.unit
+			}
+			return fmt.Sprint(int64(v), string(u.suffix))
+		}
+	}
+	return fmt.Sprint(int64(d/time.Hour), "H")
+}
+
+func DecodeTimeout(s string) (time.Duration, error) {
+	if len(s) < 2 {
+		return 0, errors.Errorf("invalid timeout %q", s)
+	}
+	v, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || v < 0 {
+		return 0, errors.Errorf("invalid timeout %q", s)
+	}
+	for _, u := range timeoutUnits {
+		if u.suffix == s[len(s)-1] {
+			return time.Duration(v) * u.unit, nil
+		}
+	}
+	return 0, errors.Errorf("invalid timeout unit %q", s)
+}
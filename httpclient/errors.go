@@ -0,0 +1,43 @@
+package httpclient
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrCircuitOpen is reported when a request is rejected by an open circuit breaker.
+	ErrCircuitOpen = errors.New("httpclient: circuit open")
+	// ErrRetriesExhausted is reported when every retry attempt has failed.
+	ErrRetriesExhausted = errors.New("httpclient: retries exhausted")
+	// ErrStreamTruncated is reported when a stream ends in the middle of a message.
+	ErrStreamTruncated = errors.New("httpclient: stream truncated")
+)
+
+// sentinelError ties one of the sentinels above to the underlying error so that
+// callers can match both with errors.Is / errors.As.
+type sentinelError struct {
+	sentinel error
+	err      error
+}
+
+func (e *sentinelError) Error() string {
+	if e.err == nil {
+		return e.sentinel.Error()
+	}
+	return fmt.Sprint(e.sentinel, ": ", e.err)
+}
+
+func (e *sentinelError) Is(target error) bool { return target == e.sentinel }
+func (e *sentinelError) Unwrap() error        { return e.err }
+func (e *sentinelError) Cause() error         { return e.err }
+
+// statusError reports a response whose status code was considered a failure.
+type statusError struct {
+	StatusCode int
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprint("unexpected status code: ", e.StatusCode)
+}
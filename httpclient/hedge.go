@@ -0,0 +1,107 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HedgeDoer sends a second copy of a GET or HEAD request when the first one
+// has not answered after Delay, and returns whichever answers first, cutting
+// tail latency at the cost of extra load on the downstream. The other attempt
+// is cancelled; an attempt failing only makes Do wait for the other one, if
+// any. Other methods are sent once. Second attempts are counted by host in
+// the httpclient_hedged_requests_total metric.
+type HedgeDoer struct {
+	Client HTTPClient
+	// Delay before the second attempt. Defaults to 100ms.
+	Delay time.Duration
+}
+
+type hedgeResult struct {
+	resp   *http.Response
+	err    error
+	cancel context.CancelFunc
+}
+
+func (c *HedgeDoer) Do(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return c.getClient().Do(req)
+	}
+
+	results := make(chan hedgeResult, 2)
+	send := func() {
+		ctx, cancel := context.WithCancel(req.Context())
+		resp, err := c.getClient().Do(req.Clone(ctx))
+		results <- hedgeResult{resp, err, cancel}
+	}
+	go send()
+
+	timer := time.NewTimer(c.delay())
+	defer timer.Stop()
+	pending := 1
+	var last hedgeResult
+	for {
+		select {
+		case <-timer.C:
+			getMetrics().hedge(req.URL.Host)
+			pending++
+			go send()
+			continue
+		case last = <-results:
+		}
+		pending--
+		if last.err == nil {
+			break
+		}
+		last.cancel()
+		if pending == 0 {
+			return nil, last.err
+		}
+	}
+
+	timer.Stop()
+	for ; pending > 0; pending-- {
+		go discardHedge(results)
+	}
+	resp := last.resp
+	resp.Body = cancelOnClose{resp.Body, last.cancel}
+	return resp, nil
+}
+
+// discardHedge cancels the losing attempt and closes its response, if any.
+func discardHedge(results <-chan hedgeResult) {
+	r := <-results
+	r.cancel()
+	if r.err == nil {
+		r.resp.Body.Close()
+	}
+}
+
+// cancelOnClose releases the context of the winning attempt once its body is
+// closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func (c *HedgeDoer) delay() time.Duration {
+	if c.Delay <= 0 {
+		return 100 * time.Millisecond
+	}
+	return c.Delay
+}
+
+func (c *HedgeDoer) getClient() HTTPClient {
+	if c.Client == nil {
+		return http.DefaultClient
+	}
+	return c.Client
+}
@@ -0,0 +1,37 @@
+package httpclient
+
+import "sync"
+
+// Circuit states, as reported in the httpclient_circuit_state metric.
+const (
+	circuitClosed   = "closed"
+	circuitHalfOpen = "half_open"
+	circuitOpen     = "open"
+)
+
+// clientMetrics counts how often RetryDoer, HedgeDoer and CircuitBreakerDoer
+// engage, labelled by the host of the request and the name of the breaker. The
+// collectors are created on first use by newClientMetrics, backed by
+// Prometheus unless built with the nometrics tag.
+type clientMetrics struct {
+	retry            func(host string)
+	retriesExhausted func(host string)
+	hedge            func(host string)
+	// circuitTransition counts a change of the named circuit breaker to state
+	// caused by a request to host, and circuitState reports its state for
+	// each host it served.
+	circuitTransition func(name, host, state string)
+	circuitState      func(name, host, state string)
+}
+
+var (
+	metricsOnce sync.Once
+	metrics     *clientMetrics
+)
+
+func getMetrics() *clientMetrics {
+	metricsOnce.Do(func() {
+		metrics = newClientMetrics()
+	})
+	return metrics
+}
@@ -0,0 +1,15 @@
+//go:build nometrics
+// +build nometrics
+
+package httpclient
+
+// Built with the nometrics tag, client metrics are discarded.
+func newClientMetrics() *clientMetrics {
+	return &clientMetrics{
+		retry:             func(string) {},
+		retriesExhausted:  func(string) {},
+		hedge:             func(string) {},
+		circuitTransition: func(string, string, string) {},
+		circuitState:      func(string, string, string) {},
+	}
+}
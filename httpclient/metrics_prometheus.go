@@ -0,0 +1,84 @@
+//go:build !nometrics
+// +build !nometrics
+
+package httpclient
+
+import (
+	"log"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newClientMetrics registers with the default registry:
+//
+//	httpclient_retries_total{host}                        retries, first attempts excluded
+//	httpclient_retries_exhausted_total{host}              requests failing every attempt
+//	httpclient_hedged_requests_total{host}                second attempts fired by HedgeDoer
+//	httpclient_circuit_transitions_total{name,host,state} state changes of circuit breakers
+//	httpclient_circuit_state{name,host}                   0 closed, 1 half open, 2 open
+func newClientMetrics() *clientMetrics {
+	retries := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "httpclient_retries_total",
+		Help: "Number of retried requests by host, first attempts excluded.",
+	}, []string{"host"})
+	exhausted := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "httpclient_retries_exhausted_total",
+		Help: "Number of requests that failed every attempt by host.",
+	}, []string{"host"})
+	hedged := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "httpclient_hedged_requests_total",
+		Help: "Number of hedged second attempts fired by host.",
+	}, []string{"host"})
+	transitions := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "httpclient_circuit_transitions_total",
+		Help: "Number of circuit breaker state changes by breaker name, host of the request causing it and new state.",
+	}, []string{"name", "host", "state"})
+	state := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "httpclient_circuit_state",
+		Help: "State of the circuit breaker by name and host it served: 0 closed, 1 half open, 2 open.",
+	}, []string{"name", "host"})
+
+	if c, ok := register(retries).(*prometheus.CounterVec); ok {
+		retries = c
+	}
+	if c, ok := register(exhausted).(*prometheus.CounterVec); ok {
+		exhausted = c
+	}
+	if c, ok := register(hedged).(*prometheus.CounterVec); ok {
+		hedged = c
+	}
+	if c, ok := register(transitions).(*prometheus.CounterVec); ok {
+		transitions = c
+	}
+	if c, ok := register(state).(*prometheus.GaugeVec); ok {
+		state = c
+	}
+
+	stateValues := map[string]float64{circuitClosed: 0, circuitHalfOpen: 1, circuitOpen: 2}
+	return &clientMetrics{
+		retry:            func(host string) { retries.WithLabelValues(host).Inc() },
+		retriesExhausted: func(host string) { exhausted.WithLabelValues(host).Inc() },
+		hedge:            func(host string) { hedged.WithLabelValues(host).Inc() },
+		circuitTransition: func(name, host, s string) {
+			transitions.WithLabelValues(name, host, s).Inc()
+		},
+		circuitState: func(name, host, s string) {
+			state.WithLabelValues(name, host).Set(stateValues[s])
+		},
+	}
+}
+
+// register registers c with the default registry, returning the collector
+// already registered under the same name, e.g. by the application, if any.
+// Other registration errors are logged and leave c unregistered.
+func register(c prometheus.Collector) prometheus.Collector {
+	err := prometheus.Register(c)
+	if err == nil {
+		return c
+	}
+	if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+		return are.ExistingCollector
+	}
+	log.Printf("[WARN] Metrics: %v\n", err)
+	return c
+}
@@ -0,0 +1,79 @@
+package httpclient
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimitedDoer caps the rate of requests sent through the wrapped client
+// with a token bucket. A request waits for a token, or fails with the context
+// error if its context ends first.
+type RateLimitedDoer struct {
+	Client HTTPClient
+	// Rate is the number of requests allowed per second. Zero disables the limit.
+	Rate float64
+	// Burst is the number of requests that may be sent at once. Defaults to 1.
+	Burst int
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func (c *RateLimitedDoer) Do(req *http.Request) (*http.Response, error) {
+	if c.Rate > 0 {
+		if err := c.wait(req); err != nil {
+			return nil, err
+		}
+	}
+	return c.getClient().Do(req)
+}
+
+// wait takes a token, going into debt if there is none, and sleeps until the
+// debt is paid off. The token is given back if the request is cancelled.
+func (c *RateLimitedDoer) wait(req *http.Request) error {
+	c.mu.Lock()
+	now := time.Now()
+	if c.last.IsZero() {
+		c.tokens = float64(c.burst())
+	} else {
+		c.tokens += now.Sub(c.last).Seconds() * c.Rate
+		if max := float64(c.burst()); c.tokens > max {
+			c.tokens = max
+		}
+	}
+	c.last = now
+	c.tokens--
+	delay := time.Duration(-c.tokens / c.Rate * float64(time.Second))
+	c.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		c.mu.Lock()
+		c.tokens++
+		c.mu.Unlock()
+		return req.Context().Err()
+	}
+}
+
+func (c *RateLimitedDoer) burst() int {
+	if c.Burst <= 0 {
+		return 1
+	}
+	return c.Burst
+}
+
+func (c *RateLimitedDoer) getClient() HTTPClient {
+	if c.Client == nil {
+		return http.DefaultClient
+	}
+	return c.Client
+}
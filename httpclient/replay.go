@@ -0,0 +1,76 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RecordedRequest is one line of the NDJSON recordings written by the corekit
+// RecordRequests middleware and read by Replay.
+type RecordedRequest struct {
+	Time   time.Time   `json:"time"`
+	Method string      `json:"method"`
+	URI    string      `json:"uri"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+	// Truncated is set when the body was not recorded in full: too large, or
+	// not JSON while fields had to be redacted.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ErrTruncatedRecord is passed to the Replay callback for recordings without
+// their full body; they are not sent.
+var ErrTruncatedRecord = errors.New("httpclient: recorded body is truncated")
+
+// Replay sends the requests recorded in records to baseURL one after the
+// other and passes each response, or error, to onResponse, which must close
+// the response body. Redacted headers are sent as recorded.
+func Replay(ctx context.Context, client HTTPClient, baseURL string, records io.Reader, onResponse func(rec RecordedRequest, resp *http.Response, err error)) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	rd := bufio.NewReader(records)
+	for line := 1; ; line++ {
+		b, err := rd.ReadBytes('\n')
+		if len(bytes.TrimSpace(b)) > 0 {
+			var rec RecordedRequest
+			if uerr := json.Unmarshal(b, &rec); uerr != nil {
+				return errors.Wrapf(uerr, "Replay [decode line %d]", line)
+			}
+			if rec.Truncated {
+				onResponse(rec, nil, ErrTruncatedRecord)
+			} else {
+				resp, serr := replayOne(ctx, client, baseURL, rec)
+				onResponse(rec, resp, serr)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "Replay [read records]")
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+func replayOne(ctx context.Context, client HTTPClient, baseURL string, rec RecordedRequest) (*http.Response, error) {
+	req, err := http.NewRequest(rec.Method, fmt.Sprint(baseURL, rec.URI), bytes.NewReader(rec.Body))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Replay [Method: %s URI: %s]", rec.Method, rec.URI)
+	}
+	for k, v := range rec.Header {
+		req.Header[k] = v
+	}
+	return client.Do(req.WithContext(ctx))
+}
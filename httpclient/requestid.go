@@ -0,0 +1,27 @@
+package httpclient
+
+import (
+	"net/http"
+
+	"github.com/t-ksn/core-kit/requestid"
+)
+
+// RequestIDDoer forwards the request ID found in the request context (set by
+// the corekit RequestIDs option) as the X-Request-ID header.
+type RequestIDDoer struct {
+	Client HTTPClient
+}
+
+func (c *RequestIDDoer) Do(req *http.Request) (*http.Response, error) {
+	if id, ok := requestid.FromContext(req.Context()); ok && req.Header.Get(requestid.Header) == "" {
+		req.Header.Set(requestid.Header, id)
+	}
+	return c.getClient().Do(req)
+}
+
+func (c *RequestIDDoer) getClient() HTTPClient {
+	if c.Client == nil {
+		return http.DefaultClient
+	}
+	return c.Client
+}
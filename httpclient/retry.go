@@ -0,0 +1,120 @@
+package httpclient
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/t-ksn/core-kit/requestid"
+)
+
+// IdempotencyKeyHeader identifies the attempts of one logical request.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// RetryDoer retries requests that failed with a transport error or a 5xx/429
+// response. When all attempts fail, Do returns an error matching
+// ErrRetriesExhausted and wrapping the last failure. If the last attempt got a
+// response, it is returned along with the error so that its error body can be
+// read; the caller must close it. A request whose context is done while
+// waiting to retry returns the context error.
+// Retries and exhausted requests are counted by host in the
+// httpclient_retries_total and httpclient_retries_exhausted_total metrics.
+//
+// POST and PATCH requests get a random Idempotency-Key, unless they carry one,
+// sent unchanged with every attempt. Served by a corekit service using the
+// Idempotency middleware, the request then takes effect at most once: a retry
+// of an attempt that completed gets the stored response, and a retry while it
+// is still running gets a 409 Conflict, which is retried too.
+type RetryDoer struct {
+	Client HTTPClient
+	// MaxAttempts is the total number of attempts, including the first one. Defaults to 3.
+	MaxAttempts int
+	// Backoff is the delay before the first retry; it doubles on each attempt. Defaults to 100ms.
+	Backoff time.Duration
+}
+
+func (c *RetryDoer) Do(req *http.Request) (*http.Response, error) {
+	attempts := c.MaxAttempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+	backoff := c.Backoff
+	if backoff <= 0 {
+		backoff = 100 * time.Millisecond
+	}
+
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return c.getClient().Do(req) // body can not be replayed
+	}
+
+	keyed := req.Header.Get(IdempotencyKeyHeader) != ""
+	if !keyed && (req.Method == http.MethodPost || req.Method == http.MethodPatch) {
+		// The caller may reuse req for other logical requests.
+		req = req.Clone(req.Context())
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+		req.Header.Set(IdempotencyKeyHeader, requestid.New())
+		keyed = true
+	}
+
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, errors.Wrap(err, "RetryDoer.Do [GetBody]")
+				}
+				req.Body = body
+			}
+
+			timer := time.NewTimer(backoff)
+			select {
+			case <-req.Context().Done():
+				timer.Stop()
+				return nil, req.Context().Err()
+			case <-timer.C:
+			}
+			backoff *= 2
+			getMetrics().retry(req.URL.Host)
+		}
+
+		resp, err := c.getClient().Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if !retryableStatus(resp.StatusCode) && !(keyed && inProgress(resp)) {
+			return resp, nil
+		}
+		if i == attempts-1 {
+			getMetrics().retriesExhausted(req.URL.Host)
+			return resp, &sentinelError{ErrRetriesExhausted, statusError{resp.StatusCode}}
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		lastErr = statusError{resp.StatusCode}
+	}
+	getMetrics().retriesExhausted(req.URL.Host)
+	return nil, &sentinelError{ErrRetriesExhausted, lastErr}
+}
+
+// inProgress reports the 409 Conflict answered by the Idempotency middleware
+// while an earlier attempt with the same key is running.
+func inProgress(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusConflict && resp.Header.Get("Retry-After") != ""
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+func (c *RetryDoer) getClient() HTTPClient {
+	if c.Client == nil {
+		return http.DefaultClient
+	}
+	return c.Client
+}
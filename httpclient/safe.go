@@ -0,0 +1,138 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnsafeAddress is reported by SafeDoer for requests to a loopback,
+// private, link-local or otherwise internal address.
+var ErrUnsafeAddress = errors.New("httpclient: address not allowed")
+
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// SafeDoer protects calls to user supplied URLs against SSRF by refusing
+// hosts that resolve to internal addresses, unless listed in AllowedHosts.
+//
+// With a nil Client it uses its own client checking the address of every
+// connection it dials, which also covers redirects and DNS rebinding. A given
+// *http.Client gets each redirect checked; other clients only the first URL.
+type SafeDoer struct {
+	Client HTTPClient
+	// AllowedHosts are host names or IPs allowed even if internal.
+	AllowedHosts []string
+
+	once sync.Once
+	safe *http.Client
+}
+
+func (c *SafeDoer) Do(req *http.Request) (*http.Response, error) {
+	if c.Client == nil {
+		c.once.Do(c.buildClient)
+		return c.safe.Do(req)
+	}
+
+	if err := c.check(req.Context(), req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	hc, ok := c.Client.(*http.Client)
+	if !ok {
+		return c.Client.Do(req)
+	}
+	checked := *hc
+	checked.CheckRedirect = func(next *http.Request, via []*http.Request) error {
+		if err := c.check(next.Context(), next.URL.Hostname()); err != nil {
+			return err
+		}
+		if hc.CheckRedirect != nil {
+			return hc.CheckRedirect(next, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return checked.Do(req)
+}
+
+// check resolves host and fails if any of its addresses is internal.
+func (c *SafeDoer) check(ctx context.Context, host string) error {
+	if c.allowed(host) {
+		return nil
+	}
+	_, err := c.resolve(ctx, host)
+	return err
+}
+
+// resolve returns the addresses of host, failing if any of them is internal.
+func (c *SafeDoer) resolve(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		if !isPublicIP(ip) {
+			return nil, &sentinelError{ErrUnsafeAddress, fmt.Errorf("%s", host)}
+		}
+		return []net.IP{ip}, nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, errors.Wrapf(err, "SafeDoer [resolve %s]", host)
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, a := range addrs {
+		if !isPublicIP(a.IP) {
+			return nil, &sentinelError{ErrUnsafeAddress, fmt.Errorf("%s resolves to %s", host, a.IP)}
+		}
+		ips = append(ips, a.IP)
+	}
+	return ips, nil
+}
+
+func (c *SafeDoer) allowed(host string) bool {
+	for _, h := range c.AllowedHosts {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+// buildClient creates a client that dials the checked addresses themselves, so
+// the name cannot resolve to something else between the check and the dial.
+func (c *SafeDoer) buildClient() {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if c.allowed(host) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		ips, err := c.resolve(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			var conn net.Conn
+			if conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port)); err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+	c.safe = &http.Client{Transport: t}
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip))
+}
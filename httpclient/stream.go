@@ -0,0 +1,224 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/t-ksn/core-kit/apierror"
+)
+
+// StreamError is returned by Stream when the server reported an error after
+// the stream started.
+type StreamError struct {
+	Err apierror.APIError
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("stream aborted by server: %s", e.Err.Error())
+}
+
+func (e *StreamError) Unwrap() error { return e.Err }
+
+// TruncatedStreamError is returned by Stream when the connection ended in the
+// middle of a message: the body failed to read, or ended with an NDJSON line
+// lacking its newline or an event lacking its blank line. It matches
+// ErrStreamTruncated. Every message before was complete and passed on, so the
+// caller may resume after the last one.
+type TruncatedStreamError struct {
+	// Partial is the incomplete message, not passed to onMessage.
+	Partial []byte
+	// Err is the read error, nil when the body ended early.
+	Err error
+}
+
+func (e *TruncatedStreamError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%s: %d bytes of incomplete message", ErrStreamTruncated, len(e.Partial))
+	}
+	return fmt.Sprint(ErrStreamTruncated, ": ", e.Err)
+}
+
+func (e *TruncatedStreamError) Is(target error) bool { return target == ErrStreamTruncated }
+func (e *TruncatedStreamError) Unwrap() error        { return e.Err }
+
+type streamErrorFrame struct {
+	apierror.APIError
+	Status int `json:"status"`
+}
+
+func (f streamErrorFrame) toError() *StreamError {
+	f.APIError.StatusCode = f.Status
+	return &StreamError{Err: f.APIError}
+}
+
+// Stream calls an event stream endpoint and passes the data of every message
+// to onMessage until the stream ends. Both Server-Sent Events and NDJSON are
+// understood; an error frame sent by the server is returned as *StreamError,
+// and a stream cut short as *TruncatedStreamError. A stream ending cleanly
+// returns nil.
+func (c *VChatClient) Stream(ctx context.Context, method string, url string, payload interface{}, onMessage func(data []byte) error) error {
+	var reqBody []byte
+	var err error
+
+	if payload != nil {
+		reqBody, err = c.codec().Marshal(payload)
+		if err != nil {
+			return errors.Wrap(err, "VChatClient.Stream [JSON marshal payload]")
+		}
+	}
+	req, err := http.NewRequest(method, fmt.Sprint(c.ServiceAddress, url), bytes.NewReader(reqBody))
+	if err != nil {
+		return errors.Wrapf(err, "VChatClient.Stream [Method: %s Path: %s ]", method, url)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("content-type", "application/json")
+	req.Header.Set("Accept", "text/event-stream, application/x-ndjson")
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set(c.deadlineHeader(), EncodeTimeout(time.Until(deadline)))
+	}
+
+	resp, err := c.getHTTPClient().Do(req)
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return errors.Wrapf(err, "VChatClient.Stream [Send request]")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return apierror.EntityNotFoundErr
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return errors.Wrapf(err, "VChatClient.Stream [ReadBody (Method: %s Path: %s)]", method, url)
+		}
+		var verr apierror.APIError
+		if err = c.codec().Unmarshal(body, &verr); err != nil {
+			return errors.Wrapf(err, "VChatClient.Stream [UnmarshalResponseErr(status code: %v body: %s)]", resp.StatusCode, body)
+		}
+		verr.StatusCode = resp.StatusCode
+		return verr
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return c.readSSE(resp.Body, onMessage)
+	}
+	return c.readNDJSON(resp.Body, onMessage)
+}
+
+// StreamJSON is Stream with every message decoded into a value returned by
+// newValue, typically a pointer to a new struct, then passed to onValue:
+//
+//	err := client.StreamJSON(ctx, "GET", "/events", nil,
+//		func() interface{} { return &Event{} },
+//		func(v interface{}) error { return handle(v.(*Event)) })
+//	if errors.Is(err, httpclient.ErrStreamTruncated) {
+//		// resume after the last event handled
+//	}
+func (c *VChatClient) StreamJSON(ctx context.Context, method string, url string, payload interface{}, newValue func() interface{}, onValue func(v interface{}) error) error {
+	return c.Stream(ctx, method, url, payload, func(data []byte) error {
+		v := newValue()
+		if err := c.codec().Unmarshal(data, v); err != nil {
+			return errors.Wrapf(err, "VChatClient.StreamJSON [Unmarshal message: %s]", data)
+		}
+		return onValue(v)
+	})
+}
+
+func (c *VChatClient) readNDJSON(body io.Reader, onMessage func(data []byte) error) error {
+	rd := bufio.NewReader(body)
+	for {
+		line, err := rd.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if err != nil {
+			if len(line) == 0 && err == io.EOF {
+				return nil
+			}
+			return c.truncated(line, err)
+		}
+		if len(line) > 0 {
+			if serr := c.ndjsonError(line); serr != nil {
+				return serr
+			}
+			if cerr := onMessage(line); cerr != nil {
+				return cerr
+			}
+		}
+	}
+}
+
+// truncated reports a stream ending with partial after the read error err,
+// or early when err is io.EOF. A partial error line still counts as the
+// server's error.
+func (c *VChatClient) truncated(partial []byte, err error) error {
+	if serr := c.ndjsonError(partial); serr != nil {
+		return serr
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return &TruncatedStreamError{Partial: partial, Err: err}
+}
+
+// ndjsonError detects the {"error": {..., "status": N}} line sent by the
+// server when the stream fails. Other lines starting with an error field are
+// messages.
+func (c *VChatClient) ndjsonError(line []byte) error {
+	if !bytes.HasPrefix(line, []byte(`{"error":`)) {
+		return nil
+	}
+	var frame map[string]*streamErrorFrame
+	if err := c.codec().Unmarshal(line, &frame); err != nil || len(frame) != 1 || frame["error"] == nil || frame["error"].Status == 0 {
+		return nil
+	}
+	return frame["error"].toError()
+}
+
+func (c *VChatClient) readSSE(body io.Reader, onMessage func(data []byte) error) error {
+	rd := bufio.NewReader(body)
+	var event string
+	var data [][]byte
+	for {
+		line, err := rd.ReadBytes('\n')
+		line = bytes.TrimRight(line, "\r\n")
+		switch {
+		case len(line) == 0 && err == nil:
+			if len(data) > 0 {
+				msg := bytes.Join(data, []byte("\n"))
+				if event == "error" {
+					var frame streamErrorFrame
+					if uerr := c.codec().Unmarshal(msg, &frame); uerr != nil {
+						return errors.Wrapf(uerr, "VChatClient.Stream [Unmarshal error event: %s]", msg)
+					}
+					return frame.toError()
+				}
+				if cerr := onMessage(msg); cerr != nil {
+					return cerr
+				}
+			}
+			event, data = "", nil
+		case bytes.HasPrefix(line, []byte("event:")):
+			event = string(bytes.TrimSpace(line[len("event:"):]))
+		case bytes.HasPrefix(line, []byte("data:")):
+			data = append(data, bytes.TrimPrefix(line[len("data:"):], []byte(" ")))
+		}
+		if err == io.EOF && len(line) == 0 && len(data) == 0 {
+			return nil
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return &TruncatedStreamError{Partial: bytes.Join(data, []byte("\n")), Err: err}
+		}
+	}
+}
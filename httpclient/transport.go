@@ -0,0 +1,70 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ClientOption configures the *http.Client built by NewClient.
+type ClientOption func(c *http.Client, t *http.Transport) error
+
+// NewClient builds an *http.Client on top of a copy of http.DefaultTransport.
+// The result can be used as VChatClient.Client.
+func NewClient(opts ...ClientOption) (*http.Client, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	c := &http.Client{Transport: t}
+	for _, o := range opts {
+		if err := o(c, t); err != nil {
+			return nil, errors.Wrap(err, "httpclient.NewClient")
+		}
+	}
+	return c, nil
+}
+
+func Timeout(d time.Duration) ClientOption {
+	return func(c *http.Client, t *http.Transport) error {
+		c.Timeout = d
+		return nil
+	}
+}
+
+func TLSConfig(cfg *tls.Config) ClientOption {
+	return func(c *http.Client, t *http.Transport) error {
+		t.TLSClientConfig = cfg
+		return nil
+	}
+}
+
+// ClientCert configures mutual TLS with the given client certificate. caFile
+// is optional and replaces the system roots used to verify the server.
+func ClientCert(certFile, keyFile, caFile string) ClientOption {
+	return func(c *http.Client, t *http.Transport) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return errors.Wrap(err, "ClientCert [load key pair]")
+		}
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		t.TLSClientConfig.Certificates = append(t.TLSClientConfig.Certificates, cert)
+
+		if caFile == "" {
+			return nil
+		}
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return errors.Wrap(err, "ClientCert [read CA]")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.Errorf("ClientCert [no certificates in %s]", caFile)
+		}
+		t.TLSClientConfig.RootCAs = pool
+		return nil
+	}
+}
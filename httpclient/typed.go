@@ -0,0 +1,35 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+)
+
+// Get sends a GET request with c and returns the response decoded as T:
+//
+//	user, err := httpclient.Get[User](ctx, client, "/users/"+id)
+//
+// Retries, circuit breaking and the like apply through c.Client as for Send.
+func Get[T any](ctx context.Context, c *VChatClient, url string) (T, error) {
+	return send[T](ctx, c, http.MethodGet, url, nil)
+}
+
+// Post sends payload with a POST request and returns the response decoded as T.
+func Post[T any](ctx context.Context, c *VChatClient, url string, payload interface{}) (T, error) {
+	return send[T](ctx, c, http.MethodPost, url, payload)
+}
+
+// Put sends payload with a PUT request and returns the response decoded as T.
+func Put[T any](ctx context.Context, c *VChatClient, url string, payload interface{}) (T, error) {
+	return send[T](ctx, c, http.MethodPut, url, payload)
+}
+
+// send returns the zero T on error, rather than a partially decoded one.
+func send[T any](ctx context.Context, c *VChatClient, method, url string, payload interface{}) (T, error) {
+	var result T
+	if err := c.Send(ctx, method, url, payload, &result); err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
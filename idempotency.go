@@ -0,0 +1,184 @@
+package corekit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/t-ksn/core-kit/apierror"
+	"github.com/t-ksn/core-kit/httpclient"
+)
+
+// StoredResponse is a response kept by an IdempotencyStore for replay.
+type StoredResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	// RequestHash is the SHA-256 of the request body that produced it.
+	RequestHash string
+}
+
+// IdempotencyStore remembers the requests made with an Idempotency-Key.
+// Implementations shared by several instances, e.g. backed by Redis, make the
+// guarantee hold across a cluster.
+type IdempotencyStore interface {
+	// Reserve claims key for a new request and reports true. When key was
+	// claimed before it reports false along with the stored response, or nil
+	// while the first request is still running.
+	Reserve(ctx context.Context, key string, ttl time.Duration) (*StoredResponse, bool, error)
+	// Complete stores the response of the request that claimed key.
+	Complete(ctx context.Context, key string, resp StoredResponse, ttl time.Duration) error
+	// Release forgets key so that the request may be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// Idempotency makes POST and PATCH requests carrying an Idempotency-Key take
+// effect at most once within ttl (24h by default), keys being scoped to the
+// caller (the Identity set by an authentication middleware running before),
+// method and path. The first request runs the handler; a repeated one gets
+// its stored response, or a 409 Conflict with Retry-After while the first is
+// still running. A key reused with a different body is rejected with
+// apierror.IdempotencyKeyReusedErr (422). Together with
+// httpclient.RetryDoer, which sends the same key with every attempt, retries
+// cannot apply a request twice.
+//
+// 5xx responses are not stored, nor are responses over 1MB, streamed ones or
+// those of a panicking handler: the key is released and a retry runs the
+// handler again, which must then have had no effect.
+func Idempotency(store IdempotencyStore, ttl time.Duration) Middleware {
+	if store == nil {
+		panic("corekit: Idempotency with nil store")
+	}
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(httpclient.IdempotencyKeyHeader)
+			if key == "" || (r.Method != http.MethodPost && r.Method != http.MethodPatch) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			id, _ := IdentityFromContext(r.Context())
+			key = id.Subject + " " + r.Method + " " + r.URL.Path + " " + key
+
+			body, err := readBody(r)
+			if err != nil {
+				WriteError(w, r, err)
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			sum := sha256.Sum256(body)
+			hash := hex.EncodeToString(sum[:])
+
+			stored, ok, err := store.Reserve(r.Context(), key, ttl)
+			if err != nil {
+				WriteError(w, r, err)
+				return
+			}
+			if !ok {
+				if stored == nil {
+					w.Header().Set("Retry-After", "1")
+					w.WriteHeader(http.StatusConflict)
+					return
+				}
+				if stored.RequestHash != "" && stored.RequestHash != hash {
+					WriteError(w, r, apierror.IdempotencyKeyReusedErr)
+					return
+				}
+				replayStored(w, stored)
+				return
+			}
+
+			cw := &captureWriter{ResponseWriter: w, header: http.Header{}}
+			completed := false
+			defer func() {
+				if !completed {
+					store.Release(context.Background(), key)
+				}
+			}()
+			next.ServeHTTP(cw, r)
+			cw.flush()
+
+			status := cw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if cw.passthrough || status >= http.StatusInternalServerError {
+				return
+			}
+			resp := StoredResponse{Status: status, Header: cw.header, Body: cw.buf.Bytes(), RequestHash: hash}
+			if err := store.Complete(context.Background(), key, resp, ttl); err != nil {
+				serviceLog(r)("[ERROR] Idempotency: store response: %+v", err)
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+func replayStored(w http.ResponseWriter, resp *StoredResponse) {
+	for k, v := range resp.Header {
+		w.Header()[k] = append([]string(nil), v...)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.Header().Set("Content-Length", strconv.Itoa(len(resp.Body)))
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+}
+
+// MemoryIdempotencyStore keeps the idempotency keys in memory, for a single
+// instance.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	entries map[string]idempotencyEntry
+	swept   time.Time
+}
+
+type idempotencyEntry struct {
+	resp    *StoredResponse
+	expires time.Time
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{entries: map[string]idempotencyEntry{}}
+}
+
+func (s *MemoryIdempotencyStore) Reserve(ctx context.Context, key string, ttl time.Duration) (*StoredResponse, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.swept) > time.Minute {
+		for k, e := range s.entries {
+			if now.After(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.swept = now
+	}
+	if e, ok := s.entries[key]; ok && now.Before(e.expires) {
+		return e.resp, false, nil
+	}
+	s.entries[key] = idempotencyEntry{expires: now.Add(ttl)}
+	return nil, true, nil
+}
+
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, key string, resp StoredResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = idempotencyEntry{resp: &resp, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
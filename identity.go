@@ -0,0 +1,81 @@
+package corekit
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/t-ksn/core-kit/apierror"
+)
+
+// Identity is the authenticated caller of a request. Authentication
+// middlewares, built in or not, store it with WithIdentity and handlers read
+// it with IdentityFromContext.
+type Identity struct {
+	// Subject identifies the caller, e.g. a user or service account ID.
+	Subject string
+	Scopes  []string
+	// Claims holds any other attributes provided by the authentication scheme.
+	Claims map[string]interface{}
+}
+
+// HasScope reports whether the identity was granted scope.
+func (id Identity) HasScope(scope string) bool {
+	for _, s := range id.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type identityKey struct{}
+
+// identitySinkKey holds an *Identity set by WithIdentity, for middlewares
+// such as AuditLog running before authentication.
+type identitySinkKey struct{}
+
+// WithIdentity returns a copy of ctx carrying id.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	if sink, ok := ctx.Value(identitySinkKey{}).(*Identity); ok {
+		*sink = id
+	}
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext returns the identity stored by WithIdentity.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// CheckScopes is the handler side of RequireScopes: it fails with
+// apierror.UnauthorizedRequestErr without identity and with
+// apierror.ForbiddenErr if one of scopes was not granted.
+func CheckScopes(ctx context.Context, scopes ...string) error {
+	id, ok := IdentityFromContext(ctx)
+	if !ok {
+		return apierror.UnauthorizedRequestErr
+	}
+	for _, s := range scopes {
+		if !id.HasScope(s) {
+			return apierror.ForbiddenErr
+		}
+	}
+	return nil
+}
+
+// RequireScopes rejects requests whose identity lacks one of scopes. It runs
+// after an authentication middleware, typically on a Group:
+//
+//	admin := svc.Group("/admin", corekit.RequireScopes("admin"))
+func RequireScopes(scopes ...string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := CheckScopes(r.Context(), scopes...); err != nil {
+				WriteError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
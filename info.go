@@ -0,0 +1,84 @@
+package corekit
+
+import (
+	"bytes"
+	"net/http"
+	"sort"
+)
+
+// infoEntry is a top-level field of the /info response. The fields are
+// written in order, each marshalled once, so that InfoField values keep the
+// precision and field order their own marshalling gives them.
+type infoEntry struct {
+	key   string
+	value interface{}
+}
+
+// infoHandler serves /info. It stops calling the dependency functions once
+// the client is gone.
+func infoHandler(o *Options) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+
+		var entries []infoEntry
+		if o.name != "" {
+			entries = append(entries, infoEntry{"name", o.name})
+		}
+		if o.version != "" {
+			entries = append(entries, infoEntry{"version", o.version})
+		}
+		if len(o.params) > 0 {
+			entries = append(entries, infoEntry{"params", o.params})
+		}
+		if len(o.dependenciesInfo) > 0 {
+			deps := map[string]interface{}{}
+			for name, d := range o.dependenciesInfo {
+				if r.Context().Err() != nil {
+					markDisconnected(w)
+					return
+				}
+				deps[name] = d(r.Context())
+			}
+			entries = append(entries, infoEntry{"dependencies", deps})
+		}
+		if r.Context().Err() != nil {
+			markDisconnected(w)
+			return
+		}
+
+		keys := make([]string, 0, len(o.infoFields))
+		for key := range o.infoFields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	fields:
+		for _, key := range keys {
+			value := o.infoFields[key]()
+			for i := range entries {
+				if entries[i].key == key {
+					entries[i].value = value
+					continue fields
+				}
+			}
+			entries = append(entries, infoEntry{key, value})
+		}
+
+		var b bytes.Buffer
+		b.WriteByte('{')
+		for i, e := range entries {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			key, _ := o.jsonCodec.Marshal(e.key)
+			value, err := o.jsonCodec.Marshal(e.value)
+			if err != nil {
+				value = []byte("null")
+			}
+			b.Write(key)
+			b.WriteByte(':')
+			b.Write(value)
+		}
+		b.WriteString("}\n")
+		w.Write(b.Bytes())
+	})
+}
@@ -0,0 +1,60 @@
+// Package jsoncodec abstracts JSON serialization so that corekit and
+// httpclient can use a faster implementation such as jsoniter or sonic.
+package jsoncodec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Std is the encoding/json codec used by default.
+type Std struct{}
+
+func (Std) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (Std) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// NumberUnmarshaler is implemented by codecs able to decode numbers stored in
+// interface{} values as json.Number rather than float64, keeping the precision
+// of large integers and decimals.
+type NumberUnmarshaler interface {
+	UnmarshalUseNumber(data []byte, v interface{}) error
+}
+
+func (Std) UnmarshalUseNumber(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	return checkEOF(dec)
+}
+
+// StrictUnmarshaler is implemented by codecs able to reject JSON objects with
+// fields that the destination struct does not declare.
+type StrictUnmarshaler interface {
+	UnmarshalStrict(data []byte, v interface{}) error
+}
+
+func (Std) UnmarshalStrict(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	return checkEOF(dec)
+}
+
+// checkEOF fails, as json.Unmarshal does, when data follows the decoded value.
+func checkEOF(dec *json.Decoder) error {
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("json: invalid data after top-level value")
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package corekit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/t-ksn/core-kit/jsoncodec"
+)
+
+// RPCHandler handles one JSON-RPC 2.0 method. params is the raw "params"
+// member of the call, nil when absent.
+type RPCHandler func(req *http.Request, params json.RawMessage) (interface{}, error)
+
+// Error codes defined by the JSON-RPC 2.0 specification.
+const (
+	RPCParseError     = -32700
+	RPCInvalidRequest = -32600
+	RPCMethodNotFound = -32601
+	RPCInvalidParams  = -32602
+	RPCInternalError  = -32603
+	// RPCServerError is used for APIErrors without a code.
+	RPCServerError = -32000
+)
+
+// RPCError is a JSON-RPC error object. Handlers may return it to choose the
+// code; any other error goes through the usual APIError mapping, the
+// APIError code becoming the RPC code and its HTTP status part of the data.
+type RPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e RPCError) Error() string {
+	return e.Message
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcErrorData struct {
+	Status  int         `json:"status"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+var rpcNullID = json.RawMessage("null")
+
+// rpcHandler serves JSON-RPC 2.0 calls, single or batched, over POST.
+// Notifications (calls without an id) get no response; a request made only of
+// notifications is answered with 204 No Content.
+func rpcHandler(errs errorHandler, codec jsoncodec.Codec, handlers map[string]RPCHandler) http.Handler {
+	call := func(r *http.Request, raw json.RawMessage) *rpcResponse {
+		var req rpcRequest
+		if err := codec.Unmarshal(raw, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" {
+			return &rpcResponse{JSONRPC: "2.0", Error: &RPCError{Code: RPCInvalidRequest, Message: "Invalid Request"}, ID: rpcNullID}
+		}
+
+		resp := &rpcResponse{JSONRPC: "2.0", ID: req.ID}
+		if h, ok := handlers[req.Method]; !ok {
+			resp.Error = &RPCError{Code: RPCMethodNotFound, Message: "Method not found"}
+		} else if result, err := h(r, req.Params); err != nil {
+			resp.Error = toRPCError(errs, err)
+		} else if result == nil {
+			resp.Result = rpcNullID
+		} else {
+			resp.Result = result
+		}
+		if req.ID == nil {
+			return nil
+		}
+		return resp
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		write := func(v interface{}) {
+			b, _ := codec.Marshal(v)
+			w.WriteHeader(http.StatusOK)
+			w.Write(b)
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			errs.handle(w, r, errors.Wrap(err, "JSON-RPC [Read body]"))
+			return
+		}
+		body = bytes.TrimSpace(body)
+
+		if len(body) == 0 || body[0] != '[' {
+			var raw json.RawMessage
+			if err := codec.Unmarshal(body, &raw); err != nil {
+				write(rpcResponse{JSONRPC: "2.0", Error: &RPCError{Code: RPCParseError, Message: "Parse error"}, ID: rpcNullID})
+				return
+			}
+			if resp := call(r, raw); resp != nil {
+				write(resp)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		var batch []json.RawMessage
+		if err := codec.Unmarshal(body, &batch); err != nil {
+			write(rpcResponse{JSONRPC: "2.0", Error: &RPCError{Code: RPCParseError, Message: "Parse error"}, ID: rpcNullID})
+			return
+		}
+		if len(batch) == 0 {
+			write(rpcResponse{JSONRPC: "2.0", Error: &RPCError{Code: RPCInvalidRequest, Message: "Invalid Request"}, ID: rpcNullID})
+			return
+		}
+		var resps []*rpcResponse
+		for _, raw := range batch {
+			if resp := call(r, raw); resp != nil {
+				resps = append(resps, resp)
+			}
+		}
+		if len(resps) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		write(resps)
+	})
+}
+
+func toRPCError(errs errorHandler, err error) *RPCError {
+	if rpcErr, ok := errors.Cause(err).(RPCError); ok {
+		return &rpcErr
+	}
+	apiErr := errs.toAPIError(err)
+	rpcErr := &RPCError{
+		Code:    apiErr.Code,
+		Message: apiErr.Message,
+		Data:    rpcErrorData{Status: apiErr.StatusCode, Details: apiErr.Details},
+	}
+	if rpcErr.Code == 0 {
+		rpcErr.Code = RPCServerError
+		if apiErr.StatusCode >= http.StatusInternalServerError {
+			rpcErr.Code = RPCInternalError
+		}
+	}
+	if rpcErr.Message == "" {
+		rpcErr.Message = http.StatusText(apiErr.StatusCode)
+	}
+	return rpcErr
+}
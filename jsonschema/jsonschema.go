@@ -0,0 +1,57 @@
+// Package jsonschema validates request bodies against a JSON Schema. It lives
+// outside corekit so that only services using it depend on gojsonschema.
+package jsonschema
+
+import (
+	"net/http"
+
+	"github.com/t-ksn/core-kit"
+	"github.com/t-ksn/core-kit/apierror"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Violation describes a single schema violation.
+type Violation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// ValidateSchema returns a middleware rejecting request bodies that do not
+// match schema with apierror.SchemaViolationErr listing the violations.
+// Invalid JSON is rejected with apierror.JSONInvalidErr and bodies over
+// MaxBodyBytes with apierror.BodyTooLargeErr. It panics if schema itself is
+// invalid.
+func ValidateSchema(schema []byte) corekit.Middleware {
+	s, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(schema))
+	if err != nil {
+		panic("jsonschema: invalid schema: " + err.Error())
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body []byte
+			if r.Body != nil {
+				var err error
+				if body, err = corekit.RawBody(r); err != nil {
+					corekit.WriteError(w, r, err)
+					return
+				}
+			}
+
+			result, err := s.Validate(gojsonschema.NewBytesLoader(body))
+			if err != nil {
+				corekit.WriteError(w, r, apierror.JSONInvalidErr)
+				return
+			}
+			if !result.Valid() {
+				violations := make([]Violation, 0, len(result.Errors()))
+				for _, e := range result.Errors() {
+					violations = append(violations, Violation{Field: e.Field(), Description: e.Description()})
+				}
+				corekit.WriteError(w, r, apierror.SchemaViolationErr.WithDetails(violations))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
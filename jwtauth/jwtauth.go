@@ -0,0 +1,390 @@
+// Package jwtauth authenticates requests carrying a bearer JWT and exposes the
+// caller as a corekit.Identity.
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	corekit "github.com/t-ksn/core-kit"
+	"github.com/t-ksn/core-kit/apierror"
+)
+
+// Options configures JWTAuth. Either Secret (HS256/384/512) or JWKSURL
+// (RS256/384/512, ES256/384/512) must be set.
+type Options struct {
+	Secret []byte
+	// JWKSURL is fetched on first use, then every JWKSRefresh (1h by default)
+	// and when a token names an unknown key, at most once a minute.
+	JWKSURL     string
+	JWKSRefresh time.Duration
+	Client      *http.Client
+
+	// Issuer and Audience, when set, must match the iss and aud claims.
+	Issuer   string
+	Audience string
+	// Leeway tolerates clock skew when checking exp and nbf.
+	Leeway time.Duration
+}
+
+// JWTAuth validates the bearer token of every request and stores the caller
+// with corekit.WithIdentity: the sub claim as Subject, the scope (space
+// separated) or scp claim as Scopes and all claims as Claims. Requests without
+// a valid token are rejected with 401.
+func JWTAuth(opts Options) corekit.Middleware {
+	if len(opts.Secret) == 0 && opts.JWKSURL == "" {
+		panic("jwtauth: Secret or JWKSURL is required")
+	}
+	v := &verifier{opts: opts}
+	if opts.JWKSURL != "" {
+		v.jwks = &jwks{url: opts.JWKSURL, refresh: opts.JWKSRefresh, client: opts.Client}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := bearer(r)
+			if token == "" {
+				unauthorized(w, r)
+				return
+			}
+			claims, err := v.verify(r.Context(), token)
+			if err != nil {
+				unauthorized(w, r)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(corekit.WithIdentity(r.Context(), identity(claims))))
+		})
+	}
+}
+
+func bearer(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	if len(h) < 7 || !strings.EqualFold(h[:7], "bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(h[7:])
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	corekit.WriteError(w, r, apierror.UnauthorizedRequestErr)
+}
+
+func identity(claims map[string]interface{}) corekit.Identity {
+	id := corekit.Identity{Claims: claims}
+	id.Subject, _ = claims["sub"].(string)
+	switch scope := claims["scope"].(type) {
+	case string:
+		id.Scopes = strings.Fields(scope)
+	}
+	if scp, ok := claims["scp"].([]interface{}); ok && id.Scopes == nil {
+		for _, s := range scp {
+			if s, ok := s.(string); ok {
+				id.Scopes = append(id.Scopes, s)
+			}
+		}
+	}
+	return id
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type verifier struct {
+	opts Options
+	jwks *jwks
+}
+
+func (v *verifier) verify(ctx context.Context, token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("jwtauth: malformed token")
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(err, "jwtauth: signature")
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+
+	if err := v.checkSignature(ctx, h, signed, sig); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *verifier) checkSignature(ctx context.Context, h header, signed, sig []byte) error {
+	alg, ok := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}[strings.TrimLeft(h.Alg, "HSREP")]
+	if !ok || len(h.Alg) != 5 {
+		return errors.Errorf("jwtauth: unsupported alg %q", h.Alg)
+	}
+	digest := func() []byte {
+		d := alg.New()
+		d.Write(signed)
+		return d.Sum(nil)
+	}
+
+	switch h.Alg[:2] {
+	case "HS":
+		if len(v.opts.Secret) == 0 {
+			return errors.Errorf("jwtauth: alg %s not accepted", h.Alg)
+		}
+		mac := hmac.New(alg.New, v.opts.Secret)
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return errors.New("jwtauth: invalid signature")
+		}
+		return nil
+	case "RS", "ES":
+		if v.jwks == nil {
+			return errors.Errorf("jwtauth: alg %s not accepted", h.Alg)
+		}
+		key, err := v.jwks.key(ctx, h.Kid)
+		if err != nil {
+			return err
+		}
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			if h.Alg[:2] != "RS" {
+				break
+			}
+			return rsa.VerifyPKCS1v15(key, alg, digest(), sig)
+		case *ecdsa.PublicKey:
+			size := (key.Curve.Params().BitSize + 7) / 8
+			if h.Alg[:2] != "ES" || key.Curve != curveOf[h.Alg] || len(sig) != 2*size {
+				break
+			}
+			r := new(big.Int).SetBytes(sig[:size])
+			s := new(big.Int).SetBytes(sig[size:])
+			if !ecdsa.Verify(key, digest(), r, s) {
+				return errors.New("jwtauth: invalid signature")
+			}
+			return nil
+		}
+		return errors.Errorf("jwtauth: key %q does not match alg %s", h.Kid, h.Alg)
+	}
+	return errors.Errorf("jwtauth: unsupported alg %q", h.Alg)
+}
+
+func (v *verifier) checkClaims(claims map[string]interface{}) error {
+	now := time.Now()
+	if exp, ok := claims["exp"].(float64); !ok || now.After(time.Unix(int64(exp), 0).Add(v.opts.Leeway)) {
+		return errors.New("jwtauth: token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.opts.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("jwtauth: token not valid yet")
+	}
+	if v.opts.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.opts.Issuer {
+			return errors.New("jwtauth: unexpected issuer")
+		}
+	}
+	if v.opts.Audience != "" && !hasAudience(claims["aud"], v.opts.Audience) {
+		return errors.New("jwtauth: unexpected audience")
+	}
+	return nil
+}
+
+func hasAudience(aud interface{}, want string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == want
+	case []interface{}:
+		for _, a := range aud {
+			if a == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return errors.Wrap(err, "jwtauth: decode segment")
+	}
+	return errors.Wrap(json.Unmarshal(b, v), "jwtauth: decode segment")
+}
+
+// jwksFetchTimeout bounds a JWKS fetch, which is not tied to the request that
+// triggered it.
+const jwksFetchTimeout = 10 * time.Second
+
+// jwks caches the public keys of a JSON Web Key Set.
+type jwks struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	lastErr     error
+	// fetching is closed when the fetch in progress, if any, ends.
+	fetching chan struct{}
+}
+
+// key returns the key named kid. Fetches run one at a time, outside of k.mu
+// and of ctx, and are attempted at most once a minute, failed ones included;
+// a known key past the refresh interval is used while it is refreshed.
+func (k *jwks) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	refresh := k.refresh
+	if refresh <= 0 {
+		refresh = time.Hour
+	}
+	minInterval := time.Minute
+	if refresh < minInterval {
+		minInterval = refresh
+	}
+
+	k.mu.Lock()
+	key, found := k.keys[kid]
+	if found && time.Since(k.fetchedAt) < refresh {
+		k.mu.Unlock()
+		return key, nil
+	}
+	done := k.fetching
+	if done == nil && time.Since(k.attemptedAt) >= minInterval {
+		done = make(chan struct{})
+		k.fetching = done
+		k.attemptedAt = time.Now()
+		go k.fetch(done)
+	}
+	k.mu.Unlock()
+
+	if found {
+		return key, nil
+	}
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if key, ok := k.keys[kid]; ok {
+		return key, nil
+	}
+	if k.keys == nil && k.lastErr != nil {
+		return nil, k.lastErr
+	}
+	return nil, errors.Errorf("jwtauth: unknown key %q", kid)
+}
+
+// fetch loads the key set and closes done.
+func (k *jwks) fetch(done chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+	defer cancel()
+	keys, err := k.load(ctx)
+
+	k.mu.Lock()
+	if err == nil {
+		k.keys = keys
+		k.fetchedAt = time.Now()
+	}
+	k.lastErr = err
+	k.fetching = nil
+	k.mu.Unlock()
+	close(done)
+}
+
+func (k *jwks) load(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequest(http.MethodGet, k.url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "jwtauth: JWKS request")
+	}
+	client := k.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "jwtauth: fetch JWKS")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("jwtauth: fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, errors.Wrap(err, "jwtauth: decode JWKS")
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range set.Keys {
+		switch jwk.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+			e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			curve, ok := curves[jwk.Crv]
+			x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+			y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+			if !ok || errX != nil || errY != nil {
+				continue
+			}
+			keys[jwk.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	return keys, nil
+}
+
+var curves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+// curveOf is the curve each ECDSA algorithm is defined on (RFC 7518).
+var curveOf = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
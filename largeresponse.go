@@ -0,0 +1,116 @@
+package corekit
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+
+	"github.com/t-ksn/core-kit/jsoncodec"
+)
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// streamable reports whether v is a slice or array that can be encoded one
+// element at a time: not a byte slice, nor marshalling itself.
+func streamable(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return false
+		}
+	case reflect.Array:
+	default:
+		return false
+	}
+	t := v.Type()
+	if t.Elem().Kind() == reflect.Uint8 {
+		return false
+	}
+	for _, m := range []reflect.Type{jsonMarshalerType, textMarshalerType} {
+		if t.Implements(m) || reflect.PtrTo(t).Implements(m) {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeSlice encodes the elements of v one at a time. While the encoding fits
+// in max bytes it is returned for the caller to write as usual; past that the
+// status and what was buffered are sent, without Content-Length, and the rest
+// is streamed to w, so that memory stays bounded by max and the largest
+// element. streamed reports the latter, and err then the error that cut the
+// response short. An element failing to encode before that yields an empty
+// body, as a failing Marshal would.
+func encodeSlice(w http.ResponseWriter, r *http.Request, codec jsoncodec.Codec, v reflect.Value, max int) (body []byte, streamed bool, err error) {
+	sw := &spillWriter{w: w, max: max, head: r.Method == http.MethodHead}
+	sw.write([]byte{'['})
+	for i := 0; i < v.Len(); i++ {
+		if sw.spilled && sw.head {
+			return nil, true, nil
+		}
+		// Elements of a slice are addressable, and marshalled through a
+		// pointer so that MarshalJSON methods with pointer receivers apply,
+		// as with json.Marshal of the whole slice.
+		e := v.Index(i)
+		if e.CanAddr() {
+			e = e.Addr()
+		}
+		elem, err := codec.Marshal(e.Interface())
+		if err != nil {
+			if !sw.spilled {
+				return nil, false, nil
+			}
+			return nil, true, err
+		}
+		if i > 0 {
+			elem = append([]byte{','}, elem...)
+		}
+		if err := sw.write(elem); err != nil {
+			return nil, true, err
+		}
+	}
+	err = sw.write([]byte{']'})
+	if !sw.spilled {
+		return sw.buf.Bytes(), false, nil
+	}
+	return nil, true, err
+}
+
+// spillWriter buffers up to max bytes, then sends the 200 status and writes
+// through. HEAD responses get the status only.
+type spillWriter struct {
+	w       http.ResponseWriter
+	max     int
+	head    bool
+	buf     bytes.Buffer
+	spilled bool
+}
+
+func (s *spillWriter) write(b []byte) error {
+	if !s.spilled && s.buf.Len()+len(b) <= s.max {
+		s.buf.Write(b)
+		return nil
+	}
+	if !s.spilled {
+		s.spilled = true
+		s.w.Header().Del("Content-Length")
+		s.w.WriteHeader(http.StatusOK)
+		if s.head {
+			return nil
+		}
+		if _, err := s.w.Write(s.buf.Bytes()); err != nil {
+			return err
+		}
+		s.buf = bytes.Buffer{}
+	}
+	if s.head {
+		return nil
+	}
+	_, err := s.w.Write(b)
+	return err
+}
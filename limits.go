@@ -0,0 +1,34 @@
+package corekit
+
+import (
+	"net/http"
+
+	"github.com/t-ksn/core-kit/apierror"
+)
+
+const (
+	defaultMaxURLLength    = 8 << 10
+	defaultMaxHeaderLength = 8 << 10
+)
+
+// limitRequestHeaders rejects requests whose URL or any single header line is
+// longer than the limits with 431 Request Header Fields Too Large.
+func limitRequestHeaders(maxURL, maxHeader int) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(r.URL.String()) > maxURL {
+				WriteError(w, r, apierror.HeaderFieldsTooLargeErr.WithMessage("Request URL is too long"))
+				return
+			}
+			for name, values := range r.Header {
+				for _, v := range values {
+					if len(name)+len(v)+2 > maxHeader {
+						WriteError(w, r, apierror.HeaderFieldsTooLargeErr)
+						return
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
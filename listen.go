@@ -0,0 +1,40 @@
+package corekit
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// ErrAddressInUse is returned by RunContext when the port is already bound by
+// another process.
+var ErrAddressInUse = stderrors.New("corekit: address already in use")
+
+// listenError reports that the service could not start listening. Run exits
+// the process on it rather than returning as if the service had stopped.
+type listenError struct {
+	port int
+	err  error
+}
+
+func (e *listenError) Error() string {
+	if stderrors.Is(e.err, syscall.EADDRINUSE) {
+		return fmt.Sprintf("corekit: port %d is already in use, stop the process holding it or configure another port: %v", e.port, e.err)
+	}
+	return fmt.Sprintf("corekit: cannot listen on port %d: %v", e.port, e.err)
+}
+
+func (e *listenError) Is(target error) bool {
+	return target == ErrAddressInUse && stderrors.Is(e.err, syscall.EADDRINUSE)
+}
+
+func (e *listenError) Unwrap() error { return e.err }
+
+func listen(port int) (net.Listener, error) {
+	ln, err := net.Listen("tcp", fmt.Sprint(":", port))
+	if err != nil {
+		return nil, &listenError{port: port, err: err}
+	}
+	return ln, nil
+}
@@ -0,0 +1,139 @@
+package corekit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const redactedValue = "***"
+
+type LogBodiesOptions struct {
+	// MaxBytes caps how much of each body is logged. Defaults to 4KB.
+	MaxBytes int
+	// Redact lists dot separated JSON field paths (e.g. "user.password")
+	// whose values are replaced with "***".
+	Redact []string
+	Logger func(format string, args ...interface{})
+}
+
+// LogBodies logs request and response bodies. It is meant for troubleshooting
+// environments and should not be enabled in production without redaction.
+func LogBodies(opts LogBodiesOptions) Middleware {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 4 << 10
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.Printf
+	}
+	redact := make([][]string, 0, len(opts.Redact))
+	for _, p := range opts.Redact {
+		redact = append(redact, strings.Split(p, "."))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var reqBody []byte
+			if r.Body != nil {
+				reqBody, _ = ioutil.ReadAll(io.LimitReader(r.Body, int64(opts.MaxBytes)+1))
+				r.Body = readCloser{io.MultiReader(bytes.NewReader(reqBody), r.Body), r.Body}
+			}
+
+			bw := &bodyLogWriter{ResponseWriter: w, max: opts.MaxBytes, status: http.StatusOK}
+			next.ServeHTTP(bw, r)
+
+			opts.Logger("[DEBUG] Request %s %s body: %s\n", r.Method, r.URL.RequestURI(), formatBody(reqBody, opts.MaxBytes, redact))
+			opts.Logger("[DEBUG] Response %s %s status: %d body: %s\n", r.Method, r.URL.RequestURI(), bw.status, formatBody(bw.buf.Bytes(), opts.MaxBytes, redact))
+		})
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type bodyLogWriter struct {
+	http.ResponseWriter
+	buf    bytes.Buffer
+	max    int
+	status int
+}
+
+func (w *bodyLogWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *bodyLogWriter) Write(b []byte) (int, error) {
+	if rest := w.max + 1 - w.buf.Len(); rest > 0 {
+		if len(b) < rest {
+			rest = len(b)
+		}
+		w.buf.Write(b[:rest])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyLogWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *bodyLogWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// formatBody truncates the body to max bytes and redacts configured fields.
+// A body that cannot be redacted reliably (truncated or not JSON) is omitted
+// when redaction is configured.
+func formatBody(body []byte, max int, redact [][]string) string {
+	if len(body) == 0 {
+		return "<empty>"
+	}
+	truncated := len(body) > max
+	if truncated {
+		body = body[:max]
+	}
+	if len(redact) == 0 {
+		if truncated {
+			return string(body) + "...<truncated>"
+		}
+		return string(body)
+	}
+	if truncated {
+		return "<omitted: body exceeds log limit>"
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "<omitted: body is not JSON>"
+	}
+	for _, path := range redact {
+		redactPath(v, path)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func redactPath(v interface{}, path []string) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		f, ok := t[path[0]]
+		if !ok {
+			return
+		}
+		if len(path) == 1 {
+			t[path[0]] = redactedValue
+			return
+		}
+		redactPath(f, path[1:])
+	case []interface{}:
+		for _, e := range t {
+			redactPath(e, path)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package corekit
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Notifier wakes up the requests waiting for a change, typically long polls.
+// The zero value is ready to use.
+type Notifier struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+// NewNotifier returns a Notifier.
+func NewNotifier() *Notifier {
+	return &Notifier{}
+}
+
+// Changed returns a channel closed by the next call to Notify. Take it before
+// checking for changes so none is missed in between.
+func (n *Notifier) Changed() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.ch == nil {
+		n.ch = make(chan struct{})
+	}
+	return n.ch
+}
+
+// Notify wakes up every current waiter.
+func (n *Notifier) Notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.ch != nil {
+		close(n.ch)
+		n.ch = nil
+	}
+}
+
+// Wait blocks until Notify is called or ctx is done, reporting whether it was
+// notified.
+func (n *Notifier) Wait(ctx context.Context) bool {
+	select {
+	case <-n.Changed():
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// longPoll runs handler with a context cancelled after the LongPollWait or
+// when the service starts shutting down, so that held requests do not delay
+// the shutdown. A handler returning no result or the context error once the
+// wait is over gets a 204 No Content, telling the client to poll again.
+func (s *service) longPoll(handler APIHandler) http.Handler {
+	return s.wrapAPIHandler(func(r *http.Request) (interface{}, error) {
+		ctx, cancel := context.WithTimeout(r.Context(), s.options.longPollWait)
+		defer cancel()
+		go func() {
+			select {
+			case <-s.stopping:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		result, err := handler(r.WithContext(ctx))
+		if err != nil && errors.Cause(err) == ctx.Err() && r.Context().Err() == nil {
+			return NoContent, nil
+		}
+		if err == nil && result == nil {
+			return NoContent, nil
+		}
+		return result, err
+	})
+}
+
+func (s *service) LongPoll(path string, handler APIHandler) {
+	s.add(http.MethodGet, path, s.longPoll(handler))
+}
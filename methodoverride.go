@@ -0,0 +1,54 @@
+package corekit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// MethodOverrideHeader is the header read by MethodOverride.
+const MethodOverrideHeader = "X-HTTP-Method-Override"
+
+// MethodOverride routes a POST request as the method named in the
+// X-HTTP-Method-Override header or, for URL-encoded forms, in the _method
+// field, for clients that can only send GET and POST. Only PUT, PATCH and
+// DELETE may be requested; other values are ignored. The form body is left
+// intact for the handler.
+func MethodOverride() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				method := r.Header.Get(MethodOverrideHeader)
+				if method == "" {
+					method = formMethod(r)
+				}
+				switch method = strings.ToUpper(method); method {
+				case http.MethodPut, http.MethodPatch, http.MethodDelete:
+					r.Method = method
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// formMethod reads the _method field of an URL-encoded body and restores the
+// body for the next reader.
+func formMethod(r *http.Request) string {
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "application/x-www-form-urlencoded" {
+		return ""
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return ""
+	}
+	return values.Get("_method")
+}
@@ -0,0 +1,122 @@
+package corekit
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// gauge is the part of prometheus.Gauge used by the service.
+type gauge interface {
+	Set(float64)
+	Inc()
+	Dec()
+}
+
+// httpMetrics records the request and server metrics. The collectors are
+// created by newHTTPMetrics, backed by Prometheus unless built with the
+// nometrics tag, and also by OpenTelemetry with the MeterProvider option of
+// the otel tag.
+type httpMetrics struct {
+	// countRequest and observeDuration take the method, route (and status for
+	// countRequest) labels followed by the extra labels. They get the request
+	// context to attach exemplars.
+	countRequest    func(ctx context.Context, labels []string)
+	observeDuration func(ctx context.Context, labels []string, seconds float64)
+	extraLabels     []string
+
+	inFlightCount    int64
+	inFlight         gauge
+	shutdownDuration gauge
+	shutdownInFlight gauge
+	// addConnections adds delta to the number of connections in state.
+	addConnections func(state string, delta float64)
+}
+
+// instrument records request count and latency labelled with the route pattern
+// rather than the raw path to keep cardinality bounded. An empty method labels
+// requests with their own method, for routes serving any method.
+func (m *httpMetrics) instrument(method, path string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := method
+		if method == "" {
+			method = r.Method
+		}
+
+		atomic.AddInt64(&m.inFlightCount, 1)
+		m.inFlight.Inc()
+		defer func() {
+			atomic.AddInt64(&m.inFlightCount, -1)
+			m.inFlight.Dec()
+		}()
+
+		r = r.WithContext(context.WithValue(r.Context(), routeKey{}, path))
+
+		var labels *metricLabels
+		if len(m.extraLabels) > 0 {
+			labels = &metricLabels{values: map[string]string{}}
+			for _, k := range m.extraLabels {
+				labels.values[k] = ""
+			}
+			r = r.WithContext(context.WithValue(r.Context(), metricLabelsKey{}, labels))
+		}
+
+		start := time.Now()
+		rec := newStatusRecorder(w)
+		h.ServeHTTP(rec, r)
+		extra := labels.list(m.extraLabels)
+		m.observeDuration(r.Context(), append([]string{method, path}, extra...), time.Since(start).Seconds())
+		m.countRequest(r.Context(), append([]string{method, path, strconv.Itoa(rec.metricStatus())}, extra...))
+	})
+}
+
+type routeKey struct{}
+
+// routePattern returns the pattern of the route serving r, as labelled in
+// metrics, or "" outside of an instrumented route.
+func routePattern(r *http.Request) string {
+	p, _ := r.Context().Value(routeKey{}).(string)
+	return p
+}
+
+type metricLabelsKey struct{}
+
+type metricLabels struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func (l *metricLabels) list(keys []string) []string {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]string, len(keys))
+	for i, k := range keys {
+		out[i] = l.values[k]
+	}
+	return out
+}
+
+// AddMetricLabel sets an extra label on the HTTP metrics recorded for the
+// current request. Only keys declared with the MetricLabels option are kept,
+// which keeps the label set bounded.
+func AddMetricLabel(ctx context.Context, key, value string) {
+	l, ok := ctx.Value(metricLabelsKey{}).(*metricLabels)
+	if !ok {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, declared := l.values[key]; declared {
+		l.values[key] = value
+	}
+}
+
+func (m *httpMetrics) requestsInFlight() int64 {
+	return atomic.LoadInt64(&m.inFlightCount)
+}
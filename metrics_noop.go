@@ -0,0 +1,42 @@
+//go:build nometrics
+// +build nometrics
+
+package corekit
+
+import (
+	"context"
+	"net/http"
+)
+
+// Built with the nometrics tag, the service does not depend on Prometheus:
+// metrics are discarded and /metrics is not registered.
+
+type noopGauge struct{}
+
+func (noopGauge) Set(float64) {}
+func (noopGauge) Inc()        {}
+func (noopGauge) Dec()        {}
+
+func newHTTPMetrics(log func(format string, args ...interface{}), extraLabels []string, traceID TraceIDFunc) *httpMetrics {
+	return &httpMetrics{
+		countRequest:     func(context.Context, []string) {},
+		observeDuration:  func(context.Context, []string, float64) {},
+		extraLabels:      extraLabels,
+		inFlight:         noopGauge{},
+		shutdownDuration: noopGauge{},
+		shutdownInFlight: noopGauge{},
+		addConnections:   func(string, float64) {},
+	}
+}
+
+func registerGauge(log func(format string, args ...interface{}), name, help string) gauge {
+	return noopGauge{}
+}
+
+func registerCounter(log func(format string, args ...interface{}), name, help string, labels []string) func(values ...string) {
+	return func(...string) {}
+}
+
+func metricsHandler() http.Handler {
+	return nil
+}
@@ -0,0 +1,106 @@
+//go:build otel
+// +build otel
+
+package corekit
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// MeterProvider records the HTTP metrics to OpenTelemetry instruments created
+// with mp, in addition to Prometheus. Build with the nometrics tag to record
+// to OpenTelemetry only. It is only available when built with the otel tag,
+// keeping the OpenTelemetry modules out of other builds.
+func MeterProvider(mp metric.MeterProvider) Option {
+	return func(o *Options) {
+		o.recordMetrics = func(m *httpMetrics, log func(format string, args ...interface{})) {
+			m.recordToOTel(mp, log)
+		}
+	}
+}
+
+// otelScope is the instrumentation scope of the OpenTelemetry instruments.
+const otelScope = "github.com/t-ksn/core-kit"
+
+// recordToOTel makes m record requests, latency, requests in flight and
+// connections to instruments of mp as well, named after the OpenTelemetry HTTP
+// semantic conventions. Instruments that cannot be created are logged and
+// skipped.
+func (m *httpMetrics) recordToOTel(mp metric.MeterProvider, log func(format string, args ...interface{})) {
+	meter := mp.Meter(otelScope)
+
+	requests, err := meter.Int64Counter("http.server.request.count",
+		metric.WithDescription("Number of HTTP requests by method, route and status code."))
+	if err != nil {
+		log("[WARN] Metrics: %v\n", err)
+	} else {
+		count := m.countRequest
+		m.countRequest = func(ctx context.Context, labels []string) {
+			count(ctx, labels)
+			requests.Add(ctx, 1, metric.WithAttributes(m.otelAttributes(labels, "http.request.method", "http.route", "http.response.status_code")...))
+		}
+	}
+
+	duration, err := meter.Float64Histogram("http.server.request.duration",
+		metric.WithDescription("HTTP request latency by method and route."), metric.WithUnit("s"))
+	if err != nil {
+		log("[WARN] Metrics: %v\n", err)
+	} else {
+		observe := m.observeDuration
+		m.observeDuration = func(ctx context.Context, labels []string, seconds float64) {
+			observe(ctx, labels, seconds)
+			duration.Record(ctx, seconds, metric.WithAttributes(m.otelAttributes(labels, "http.request.method", "http.route")...))
+		}
+	}
+
+	active, err := meter.Int64UpDownCounter("http.server.active_requests",
+		metric.WithDescription("Number of HTTP requests currently being served."))
+	if err != nil {
+		log("[WARN] Metrics: %v\n", err)
+	} else {
+		m.inFlight = otelGauge{gauge: m.inFlight, counter: active}
+	}
+
+	connections, err := meter.Int64UpDownCounter("http.server.open_connections",
+		metric.WithDescription("Number of open connections by state (new, active, idle)."))
+	if err != nil {
+		log("[WARN] Metrics: %v\n", err)
+	} else {
+		add := m.addConnections
+		m.addConnections = func(state string, delta float64) {
+			add(state, delta)
+			connections.Add(context.Background(), int64(delta), metric.WithAttributes(attribute.String("http.connection.state", state)))
+		}
+	}
+}
+
+// otelAttributes names labels with names, then with the extra label names.
+func (m *httpMetrics) otelAttributes(labels []string, names ...string) []attribute.KeyValue {
+	names = append(names, m.extraLabels...)
+	attrs := make([]attribute.KeyValue, 0, len(labels))
+	for i, v := range labels {
+		if i < len(names) {
+			attrs = append(attrs, attribute.String(names[i], v))
+		}
+	}
+	return attrs
+}
+
+// otelGauge mirrors Inc and Dec of gauge on an OpenTelemetry up-down counter.
+type otelGauge struct {
+	gauge
+	counter metric.Int64UpDownCounter
+}
+
+func (g otelGauge) Inc() {
+	g.gauge.Inc()
+	g.counter.Add(context.Background(), 1)
+}
+
+func (g otelGauge) Dec() {
+	g.gauge.Dec()
+	g.counter.Add(context.Background(), -1)
+}
@@ -0,0 +1,111 @@
+//go:build !nometrics
+// +build !nometrics
+
+package corekit
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// newHTTPMetrics creates the request metrics. extraLabels are the additional
+// label names handlers may set with AddMetricLabel. When traceID is set,
+// latency samples carry the trace ID of the request as exemplar.
+func newHTTPMetrics(log func(format string, args ...interface{}), extraLabels []string, traceID TraceIDFunc) *httpMetrics {
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of HTTP requests by method, route and status code.",
+	}, append([]string{"method", "path", "status"}, extraLabels...))
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, append([]string{"method", "path"}, extraLabels...))
+	connections := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_server_connections",
+		Help: "Number of open connections by state (new, active, idle).",
+	}, []string{"state"})
+
+	if c, ok := registerCollector(log, requests).(*prometheus.CounterVec); ok {
+		requests = c
+	}
+	if c, ok := registerCollector(log, duration).(*prometheus.HistogramVec); ok {
+		duration = c
+	}
+	if c, ok := registerCollector(log, connections).(*prometheus.GaugeVec); ok {
+		connections = c
+	}
+
+	return &httpMetrics{
+		countRequest: func(ctx context.Context, labels []string) {
+			requests.WithLabelValues(labels...).Inc()
+		},
+		observeDuration: func(ctx context.Context, labels []string, seconds float64) {
+			obs := duration.WithLabelValues(labels...)
+			if traceID != nil {
+				if id, ok := traceID(ctx); ok {
+					if eo, ok := obs.(prometheus.ExemplarObserver); ok {
+						eo.ObserveWithExemplar(seconds, prometheus.Labels{"trace_id": id})
+						return
+					}
+				}
+			}
+			obs.Observe(seconds)
+		},
+		extraLabels: extraLabels,
+		inFlight: registerGauge(log, "http_requests_in_flight",
+			"Number of HTTP requests currently being served."),
+		shutdownDuration: registerGauge(log, "http_server_shutdown_duration_seconds",
+			"Duration of the last graceful shutdown."),
+		shutdownInFlight: registerGauge(log, "http_server_shutdown_in_flight_requests",
+			"Number of requests in flight when the last graceful shutdown started."),
+		addConnections: func(state string, delta float64) {
+			connections.WithLabelValues(state).Add(delta)
+		},
+	}
+}
+
+func registerGauge(log func(format string, args ...interface{}), name, help string) gauge {
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: help})
+	if c, ok := registerCollector(log, g).(prometheus.Gauge); ok {
+		return c
+	}
+	return g
+}
+
+// registerCounter creates a counter with the given label names and returns a
+// function incrementing it for label values.
+func registerCounter(log func(format string, args ...interface{}), name, help string, labels []string) func(values ...string) {
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)
+	if existing, ok := registerCollector(log, c).(*prometheus.CounterVec); ok {
+		c = existing
+	}
+	return func(values ...string) {
+		c.WithLabelValues(values...).Inc()
+	}
+}
+
+// registerCollector registers c with the default registry. If an identical
+// collector is already registered (by the application or by another service in
+// the same process) the existing one is returned instead of panicking. Any other
+// registration error is logged and c is returned unregistered.
+func registerCollector(log func(format string, args ...interface{}), c prometheus.Collector) prometheus.Collector {
+	err := prometheus.Register(c)
+	if err == nil {
+		return c
+	}
+	if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+		return are.ExistingCollector
+	}
+	log("[WARN] Metrics: %v\n", err)
+	return c
+}
+
+// metricsHandler serves the default Prometheus registry on /metrics, in the
+// OpenMetrics format when asked for, as needed for exemplars.
+func metricsHandler() http.Handler {
+	return promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
@@ -0,0 +1,52 @@
+package corekit
+
+import "net/http"
+
+// Middleware wraps an http.Handler with additional behaviour.
+type Middleware func(next http.Handler) http.Handler
+
+// chain wraps h so that the first middleware is the outermost one.
+func chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// DefaultMiddlewares marks where the middlewares enabled by options run when
+// passed to Use. By default they run before every Use middleware, always in
+// this order whatever the order of the options:
+//
+//	LimitRequestHeaders, MaxBodyBytes, TrustedProxies, RequestIDs,
+//	PropagateDeadline, SlowRequestLog
+//
+// so that the client address and the request ID are known to everything
+// after them. For instance Use(tracing, DefaultMiddlewares, auth) starts the
+// trace before the request ID is assigned.
+var DefaultMiddlewares Middleware = defaultMiddlewaresMarker
+
+func defaultMiddlewaresMarker(next http.Handler) http.Handler { return defaultsHere{next} }
+
+// defaultsHere is the handler of DefaultMiddlewares, telling chainUser where
+// to insert the middlewares enabled by options.
+type defaultsHere struct {
+	http.Handler
+}
+
+// chainUser wraps h with the Use middlewares mws, the first one being the
+// outermost, and with defaults in place of DefaultMiddlewares or, without
+// it, before all of them.
+func chainUser(h http.Handler, mws, defaults []Middleware) http.Handler {
+	placed := false
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+		if d, ok := h.(defaultsHere); ok {
+			h = chain(d.Handler, defaults...)
+			placed = true
+		}
+	}
+	if !placed {
+		h = chain(h, defaults...)
+	}
+	return h
+}
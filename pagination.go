@@ -0,0 +1,119 @@
+package corekit
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/t-ksn/core-kit/apierror"
+)
+
+// PageDefaults configures Pagination. Zero values fall back to the defaults
+// noted on each field.
+type PageDefaults struct {
+	Limit    int // 20
+	MaxLimit int // 100
+	Sort     string
+
+	LimitParam  string // "limit"
+	OffsetParam string // "offset"
+	SortParam   string // "sort"
+	CursorParam string // "cursor"
+}
+
+type Page struct {
+	Limit  int
+	Offset int
+	Sort   string
+	Cursor string
+}
+
+// Pagination reads the paging query parameters of r. Invalid values are
+// reported as apierror.QueryParamInvalidErr.
+func Pagination(r *http.Request, defaults PageDefaults) (Page, error) {
+	d := defaults
+	if d.Limit <= 0 {
+		d.Limit = 20
+	}
+	if d.MaxLimit <= 0 {
+		d.MaxLimit = 100
+	}
+	d.LimitParam = paramName(d.LimitParam, "limit")
+	d.OffsetParam = paramName(d.OffsetParam, "offset")
+	d.SortParam = paramName(d.SortParam, "sort")
+	d.CursorParam = paramName(d.CursorParam, "cursor")
+
+	q := r.URL.Query()
+	page := Page{
+		Limit:  d.Limit,
+		Sort:   d.Sort,
+		Cursor: q.Get(d.CursorParam),
+	}
+	if v := q.Get(d.SortParam); v != "" {
+		page.Sort = v
+	}
+
+	if v := q.Get(d.LimitParam); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > d.MaxLimit {
+			return Page{}, apierror.QueryParamInvalidErr.WithMessage(fmt.Sprintf("%s must be an integer between 1 and %d", d.LimitParam, d.MaxLimit))
+		}
+		page.Limit = n
+	}
+	if v := q.Get(d.OffsetParam); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return Page{}, apierror.QueryParamInvalidErr.WithMessage(fmt.Sprintf("%s must be a non-negative integer", d.OffsetParam))
+		}
+		page.Offset = n
+	}
+	return page, nil
+}
+
+// PageLinks builds an RFC 5988 Link header for offset pagination: "next"
+// when hasMore, "prev" and "first" past the first page. The URLs are those of
+// r, made absolute with URLFor, with only the offset changed; the route
+// parameters pat adds to the query are left out.
+func PageLinks(r *http.Request, page Page, hasMore bool, defaults PageDefaults) string {
+	offsetParam := paramName(defaults.OffsetParam, "offset")
+	link := func(offset int, rel string) string {
+		q := r.URL.Query()
+		for k := range q {
+			if strings.HasPrefix(k, ":") {
+				delete(q, k)
+			}
+		}
+		q.Set(offsetParam, strconv.Itoa(offset))
+		return fmt.Sprintf("<%s?%s>; rel=%q", URLFor(r, r.URL.EscapedPath()), q.Encode(), rel)
+	}
+
+	var links []string
+	if hasMore {
+		links = append(links, link(page.Offset+page.Limit, "next"))
+	}
+	if page.Offset > 0 {
+		prev := page.Offset - page.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		links = append(links, link(prev, "prev"), link(0, "first"))
+	}
+	return strings.Join(links, ", ")
+}
+
+// Paginated returns body with the Link header built by PageLinks.
+func Paginated(r *http.Request, body interface{}, page Page, hasMore bool, defaults PageDefaults) HeaderResponse {
+	h := http.Header{}
+	if links := PageLinks(r, page, hasMore, defaults); links != "" {
+		h.Set("Link", links)
+	}
+	return WithHeaders(body, h)
+}
+
+func paramName(name, def string) string {
+	if name == "" {
+		return def
+	}
+	return name
+}
@@ -0,0 +1,33 @@
+package corekit
+
+import (
+	"context"
+	"net/http"
+)
+
+type paramsKey struct{}
+
+func injectParams(params map[string]string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), paramsKey{}, params)))
+		})
+	}
+}
+
+// Params returns a copy of the service params set with the Param option.
+func Params(ctx context.Context) map[string]string {
+	params, _ := ctx.Value(paramsKey{}).(map[string]string)
+	cp := make(map[string]string, len(params))
+	for k, v := range params {
+		cp[k] = v
+	}
+	return cp
+}
+
+// ParamFromContext returns a single service param set with the Param option.
+func ParamFromContext(ctx context.Context, name string) (string, bool) {
+	params, _ := ctx.Value(paramsKey{}).(map[string]string)
+	v, ok := params[name]
+	return v, ok
+}
@@ -0,0 +1,160 @@
+package corekit
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/t-ksn/core-kit/apierror"
+	"github.com/t-ksn/core-kit/httpclient"
+	"github.com/t-ksn/core-kit/requestid"
+)
+
+// hopHeaders only apply to a single connection and are never forwarded.
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// ReverseProxy forwards requests to target, e.g. "http://users:8080/api",
+// whose path is prepended to the request path. Method, headers other than
+// hop-by-hop ones, query and body are forwarded with client (http.DefaultClient
+// when nil); the request ID, trace headers and the remaining deadline go along.
+// The response status, headers and body are relayed back, the body being
+// flushed as it arrives so streams keep working. Unreachable targets get
+// apierror.BadGatewayErr, and apierror.GatewayTimeoutErr past the deadline.
+//
+// The handler is registered on the mux directly, e.g. for a whole prefix:
+//
+//	svc.ServeMux().(corekit.PatRouter).AddPrefix(http.MethodGet, "/users/", corekit.ReverseProxy(usersURL, nil))
+func ReverseProxy(target string, client httpclient.HTTPClient) http.Handler {
+	base, err := url.Parse(target)
+	if err != nil {
+		panic("corekit: invalid ReverseProxy target " + target + ": " + err.Error())
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := http.NewRequest(r.Method, proxyURL(base, r.URL).String(), r.Body)
+		if err != nil {
+			WriteError(w, r, errors.Wrap(err, "ReverseProxy [Build request]"))
+			return
+		}
+		req = req.WithContext(r.Context())
+		req.ContentLength = r.ContentLength
+		if r.ContentLength == 0 {
+			req.Body = http.NoBody
+		}
+		copyHeader(req.Header, r.Header)
+		removeHopHeaders(req.Header)
+		req.Host = base.Host
+
+		if id, ok := requestid.FromContext(r.Context()); ok {
+			req.Header.Set(requestid.Header, id)
+		}
+		if deadline, ok := r.Context().Deadline(); ok {
+			req.Header.Set(httpclient.DefaultDeadlineHeader, httpclient.EncodeTimeout(time.Until(deadline)))
+		}
+		if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			if prior := r.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+				ip = strings.Join(prior, ", ") + ", " + ip
+			}
+			req.Header.Set("X-Forwarded-For", ip)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			switch r.Context().Err() {
+			case context.Canceled:
+				return
+			case context.DeadlineExceeded:
+				WriteError(w, r, apierror.GatewayTimeoutErr)
+				return
+			}
+			serviceLog(r)("[ERROR] ReverseProxy [%s %s]: %v\n", r.Method, req.URL, err)
+			WriteError(w, r, apierror.BadGatewayErr)
+			return
+		}
+		defer resp.Body.Close()
+
+		removeHopHeaders(resp.Header)
+		copyHeader(w.Header(), resp.Header)
+		w.WriteHeader(resp.StatusCode)
+		copyFlushing(w, resp.Body)
+	})
+}
+
+// proxyURL joins the target base with the path and query of in. Route
+// parameters added to the query by pat (":name") are not forwarded.
+func proxyURL(base, in *url.URL) *url.URL {
+	u := *base
+	u.Path = strings.TrimSuffix(base.Path, "/") + "/" + strings.TrimPrefix(in.Path, "/")
+	u.RawPath = ""
+
+	q := in.Query()
+	for k := range q {
+		if strings.HasPrefix(k, ":") {
+			q.Del(k)
+		}
+	}
+	for k, v := range base.Query() {
+		q[k] = append(v, q[k]...)
+	}
+	u.RawQuery = q.Encode()
+	return &u
+}
+
+func copyHeader(dst, src http.Header) {
+	for k, v := range src {
+		dst[k] = append([]string(nil), v...)
+	}
+}
+
+// removeHopHeaders drops the hop-by-hop headers, including the ones listed
+// in Connection.
+func removeHopHeaders(h http.Header) {
+	for _, v := range h.Values("Connection") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+}
+
+// copyFlushing copies body to w, flushing after every read.
+func copyFlushing(w http.ResponseWriter, body io.Reader) {
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
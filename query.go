@@ -0,0 +1,73 @@
+package corekit
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/t-ksn/core-kit/apierror"
+)
+
+// The Query helpers read a single query parameter of r, returning def when it
+// is absent or empty. Malformed values are reported as
+// apierror.QueryParamInvalidErr naming the parameter, so handlers can return
+// the error as is.
+
+var uuidPattern = regexp.MustCompile("^" + namedConstraints["uuid"] + "$")
+
+// QueryInt reads an integer parameter.
+func QueryInt(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def, queryParamErr(name, "an integer")
+	}
+	return n, nil
+}
+
+// QueryBool reads a boolean parameter: 1, t, true, 0, f, false and so on.
+func QueryBool(r *http.Request, name string, def bool) (bool, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def, queryParamErr(name, "a boolean")
+	}
+	return b, nil
+}
+
+// QueryTime reads a time parameter formatted with layout, e.g. time.RFC3339.
+func QueryTime(r *http.Request, name, layout string, def time.Time) (time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	t, err := time.Parse(layout, v)
+	if err != nil {
+		return def, queryParamErr(name, "a time formatted as "+layout)
+	}
+	return t, nil
+}
+
+// QueryUUID reads a UUID parameter in its canonical textual form.
+func QueryUUID(r *http.Request, name string, def string) (string, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	if !uuidPattern.MatchString(v) {
+		return def, queryParamErr(name, "a UUID")
+	}
+	return v, nil
+}
+
+func queryParamErr(name, expected string) apierror.APIError {
+	return apierror.QueryParamInvalidErr.WithMessage(fmt.Sprintf("%s must be %s", name, expected))
+}
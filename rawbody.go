@@ -0,0 +1,61 @@
+package corekit
+
+import (
+	"bytes"
+	stderrors "errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/t-ksn/core-kit/apierror"
+)
+
+// limitBody caps the size of request bodies; reading past max fails and is
+// reported by Bind and RawBody as apierror.BodyTooLargeErr. Requests declaring
+// a larger Content-Length are rejected before the body is read, so clients
+// waiting for 100 Continue do not upload it.
+func limitBody(max int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > max {
+				RejectUpload(w, r, apierror.BodyTooLargeErr)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, max)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RawBody reads the whole request body, e.g. to verify a webhook signature
+// over the exact bytes, and replaces r.Body so it can still be decoded with
+// Bind afterwards. Bodies over MaxBodyBytes fail with apierror.BodyTooLargeErr.
+func RawBody(r *http.Request) ([]byte, error) {
+	body, err := readBody(r)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func readBody(r *http.Request) ([]byte, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if stderrors.As(err, &tooLarge) {
+			return nil, apierror.BodyTooLargeErr
+		}
+		return nil, errors.Wrap(err, "read request body")
+	}
+	return body, nil
+}
+
+// RejectUpload answers r with err without reading its body. net/http only
+// sends 100 Continue once the body is read, so a client that sent
+// "Expect: 100-continue" does not upload anything. The connection is closed
+// afterwards since the unread body cannot be skipped.
+func RejectUpload(w http.ResponseWriter, r *http.Request, err error) {
+	w.Header().Set("Connection", "close")
+	WriteError(w, r, err)
+}
@@ -0,0 +1,73 @@
+package corekit
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/t-ksn/core-kit/jsoncodec"
+)
+
+// States reported by /ready.
+const (
+	ReadyStateStarting = "starting"
+	ReadyStateReady    = "ready"
+	ReadyStateDraining = "draining"
+)
+
+// readiness is the lifecycle state of the service as reported by /ready.
+type readiness struct {
+	mu         sync.Mutex
+	state      string
+	reason     string
+	drainUntil time.Time
+}
+
+func newReadiness() *readiness {
+	return &readiness{state: ReadyStateStarting}
+}
+
+func (rd *readiness) ready() {
+	rd.mu.Lock()
+	rd.state, rd.reason, rd.drainUntil = ReadyStateReady, "", time.Time{}
+	rd.mu.Unlock()
+}
+
+func (rd *readiness) drain(reason string, drainTime time.Duration) {
+	rd.mu.Lock()
+	rd.state, rd.reason, rd.drainUntil = ReadyStateDraining, reason, time.Now().Add(drainTime)
+	rd.mu.Unlock()
+}
+
+type readyResponse struct {
+	State         string     `json:"state"`
+	Reason        string     `json:"reason,omitempty"`
+	DrainDeadline *time.Time `json:"drain_deadline,omitempty"`
+	DrainSeconds  float64    `json:"drain_seconds,omitempty"`
+}
+
+// readyHandler answers 200 while the service accepts traffic and 503 while it
+// is starting or draining, with the state in the body.
+func readyHandler(rd *readiness, codec jsoncodec.Codec) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rd.mu.Lock()
+		resp := readyResponse{State: rd.state, Reason: rd.reason}
+		if !rd.drainUntil.IsZero() {
+			deadline := rd.drainUntil.UTC()
+			resp.DrainDeadline = &deadline
+			if left := time.Until(deadline).Seconds(); left > 0 {
+				resp.DrainSeconds = left
+			}
+		}
+		rd.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if resp.State == ReadyStateReady {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		b, _ := codec.Marshal(resp)
+		w.Write(b)
+	})
+}
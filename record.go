@@ -0,0 +1,90 @@
+package corekit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/t-ksn/core-kit/httpclient"
+)
+
+type RecordOptions struct {
+	// MaxBodyBytes caps the recorded body; larger ones are marked truncated.
+	// Defaults to 64KB.
+	MaxBodyBytes int
+	// RedactHeaders replaces the values of these headers with "***". Defaults
+	// to Authorization, Cookie and X-API-Key.
+	RedactHeaders []string
+	// RedactFields lists dot separated JSON field paths redacted from bodies,
+	// as for LogBodies. Bodies that are not JSON are then left out and the
+	// recording marked truncated.
+	RedactFields []string
+}
+
+// RecordRequests writes every request to sink as one line of NDJSON
+// (httpclient.RecordedRequest), for httpclient.Replay to send them again.
+func RecordRequests(sink io.Writer, opts RecordOptions) Middleware {
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = 64 << 10
+	}
+	if opts.RedactHeaders == nil {
+		opts.RedactHeaders = []string{"Authorization", "Cookie", "X-API-Key"}
+	}
+	redact := make([][]string, 0, len(opts.RedactFields))
+	for _, p := range opts.RedactFields {
+		redact = append(redact, strings.Split(p, "."))
+	}
+	var mu sync.Mutex
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := httpclient.RecordedRequest{
+				Time:   time.Now().UTC(),
+				Method: r.Method,
+				URI:    r.URL.RequestURI(),
+				Header: r.Header.Clone(),
+			}
+			for _, h := range opts.RedactHeaders {
+				if rec.Header.Get(h) != "" {
+					rec.Header.Set(h, redactedValue)
+				}
+			}
+			if r.Body != nil {
+				body, _ := ioutil.ReadAll(io.LimitReader(r.Body, int64(opts.MaxBodyBytes)+1))
+				r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+				rec.Body, rec.Truncated = recordedBody(body, opts.MaxBodyBytes, redact)
+			}
+
+			line, err := json.Marshal(rec)
+			if err == nil {
+				mu.Lock()
+				sink.Write(append(line, '\n'))
+				mu.Unlock()
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func recordedBody(body []byte, max int, redact [][]string) ([]byte, bool) {
+	if len(body) > max {
+		return nil, true
+	}
+	if len(redact) == 0 || len(body) == 0 {
+		return body, false
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, true
+	}
+	for _, path := range redact {
+		redactPath(v, path)
+	}
+	b, _ := json.Marshal(v)
+	return b, false
+}
@@ -0,0 +1,41 @@
+package corekit
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/t-ksn/core-kit/apierror"
+)
+
+// PanicHandler is notified about a recovered panic before the 500 response is
+// written, e.g. to report it to an error tracker.
+type PanicHandler func(ctx context.Context, recovered interface{}, stack []byte)
+
+// recoverer turns a panic in a route handler into a logged 500 response.
+// http.ErrAbortHandler is re-panicked so net/http can abort the response.
+func recoverer(log func(format string, args ...interface{}), onPanic PanicHandler, errs errorHandler) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				stack := debug.Stack()
+				log("[ERROR] Panic serving %s %s: %v\n%s", r.Method, r.URL.Path, rec, stack)
+				if onPanic != nil {
+					onPanic(r.Context(), rec, stack)
+				}
+				if sr, ok := w.(*statusRecorder); !ok || !sr.wroteHeader {
+					errs.write(w, r, errs.codec, apierror.InternalServerErr)
+				}
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
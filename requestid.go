@@ -0,0 +1,27 @@
+package corekit
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/t-ksn/core-kit/requestid"
+)
+
+// assignRequestID reuses a valid X-Request-ID sent by the client or generates
+// a new one, echoes it in the response and stores it in the request context.
+func assignRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+	})
+}
+
+// RequestID returns the ID assigned to the current request by the RequestIDs option.
+func RequestID(ctx context.Context) string {
+	id, _ := requestid.FromContext(ctx)
+	return id
+}
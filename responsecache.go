@@ -0,0 +1,139 @@
+package corekit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/t-ksn/core-kit/httpclient"
+)
+
+// ResponseCacheOptions configures ResponseCache.
+type ResponseCacheOptions struct {
+	// TTL is how long a response is served from the cache. Defaults to 1 minute.
+	TTL time.Duration
+	// Store keeps the responses. Defaults to an httpclient.MemoryCache, shared
+	// by the routes wrapped by this middleware.
+	Store httpclient.Cache
+	// Vary lists the request headers, e.g. Accept-Language, whose values are
+	// part of the cache key besides the URL. They are sent in the Vary header
+	// so that downstream caches key responses the same way.
+	Vary []string
+	// PerCaller caches authenticated requests too, keyed by the Identity in
+	// the context or else by the Authorization and Cookie headers. Responses
+	// are then marked private. Without it such requests bypass the cache.
+	PerCaller bool
+}
+
+// ResponseCache serves repeated GET requests from a cache for TTL, without
+// calling the handler, with an Age header telling how long ago the response
+// was produced. Only 200 responses are stored, and not those marked no-store
+// or private, setting cookies, larger than 1MB or streamed. Responses without
+// Cache-Control get "max-age=TTL", or "private, max-age=TTL" with PerCaller.
+// A request with Cache-Control: no-cache bypasses the cache and refreshes it.
+//
+// Requests carrying credentials (Authorization, Cookie or an Identity set by
+// an authentication middleware) bypass the cache unless PerCaller is set, so
+// that one caller's response is never served to another.
+func ResponseCache(opts ResponseCacheOptions) Middleware {
+	if opts.TTL <= 0 {
+		opts.TTL = time.Minute
+	}
+	if opts.Store == nil {
+		opts.Store = &httpclient.MemoryCache{}
+	}
+	maxAge := "max-age=" + strconv.Itoa(int(opts.TTL.Seconds()))
+	if opts.PerCaller {
+		maxAge = "private, " + maxAge
+	}
+	vary := strings.Join(opts.Vary, ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead ||
+				!opts.PerCaller && hasCredentials(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key := responseCacheKey(r, opts.Vary)
+			if opts.PerCaller {
+				key += "\n" + callerKey(r)
+			}
+
+			if !strings.Contains(r.Header.Get("Cache-Control"), "no-cache") {
+				if cached, ok := opts.Store.Get(key); ok && time.Now().Before(cached.Expires) {
+					for k, v := range cached.Header {
+						w.Header()[k] = append([]string(nil), v...)
+					}
+					age := opts.TTL - time.Until(cached.Expires)
+					w.Header().Set("Age", strconv.Itoa(int(age.Seconds())))
+					w.Header().Set("Content-Length", strconv.Itoa(len(cached.Body)))
+					w.WriteHeader(cached.StatusCode)
+					if r.Method != http.MethodHead {
+						w.Write(cached.Body)
+					}
+					return
+				}
+			}
+
+			cw := &captureWriter{ResponseWriter: w, header: http.Header{}}
+			next.ServeHTTP(cw, r)
+			if !cw.passthrough && cacheable(cw) {
+				if cw.header.Get("Cache-Control") == "" {
+					cw.header.Set("Cache-Control", maxAge)
+				}
+				if vary != "" {
+					cw.header.Add("Vary", vary)
+				}
+				if r.Method == http.MethodGet {
+					opts.Store.Set(key, &httpclient.CachedResponse{
+						StatusCode: http.StatusOK,
+						Header:     cw.header.Clone(),
+						Body:       append([]byte(nil), cw.buf.Bytes()...),
+						Expires:    time.Now().Add(opts.TTL),
+					})
+				}
+			}
+			cw.flush()
+		})
+	}
+}
+
+func cacheable(cw *captureWriter) bool {
+	if cw.status != 0 && cw.status != http.StatusOK {
+		return false
+	}
+	cc := cw.header.Get("Cache-Control")
+	return !strings.Contains(cc, "no-store") && !strings.Contains(cc, "private") &&
+		cw.header.Get("Set-Cookie") == "" && cw.header.Get("Vary") != "*"
+}
+
+func hasCredentials(r *http.Request) bool {
+	_, ok := IdentityFromContext(r.Context())
+	return ok || r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != ""
+}
+
+// callerKey identifies the caller of r for PerCaller, hashing credentials so
+// that they are not kept in the cache keys.
+func callerKey(r *http.Request) string {
+	if id, ok := IdentityFromContext(r.Context()); ok {
+		return "identity: " + id.Subject
+	}
+	sum := sha256.Sum256([]byte(r.Header.Get("Authorization") + "\n" + strings.Join(r.Header.Values("Cookie"), "; ")))
+	return "credentials: " + hex.EncodeToString(sum[:])
+}
+
+func responseCacheKey(r *http.Request, vary []string) string {
+	var b strings.Builder
+	b.WriteString(r.URL.RequestURI())
+	for _, h := range vary {
+		b.WriteString("\n")
+		b.WriteString(h)
+		b.WriteString(": ")
+		b.WriteString(strings.Join(r.Header.Values(h), ","))
+	}
+	return b.String()
+}
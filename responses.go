@@ -0,0 +1,32 @@
+package corekit
+
+import "net/http"
+
+// RedirectResponse makes wrapAPIHandler answer with a redirect and no body.
+type RedirectResponse struct {
+	URL  string
+	Code int
+}
+
+// Redirect returns a response redirecting the client to url. A zero code means 302 Found.
+func Redirect(url string, code int) RedirectResponse {
+	return RedirectResponse{URL: url, Code: code}
+}
+
+type noContent struct{}
+
+// NoContent makes wrapAPIHandler answer 204 No Content. A nil response keeps
+// answering 201 Created.
+var NoContent interface{} = noContent{}
+
+// HeaderResponse makes wrapAPIHandler send Header along with the response
+// for Body, which is handled like any other result.
+type HeaderResponse struct {
+	Header http.Header
+	Body   interface{}
+}
+
+// WithHeaders returns body with response headers.
+func WithHeaders(body interface{}, header http.Header) HeaderResponse {
+	return HeaderResponse{Header: header, Body: body}
+}
@@ -0,0 +1,90 @@
+package corekit
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// statusRecorder remembers the status code and the number of bytes written
+// while passing Flush and Hijack through to the underlying writer.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	written     int64
+	wroteHeader bool
+	// disconnected is set when the client went away before getting the response.
+	disconnected bool
+	// streamErrStatus is the status of an error reported mid-stream with
+	// StreamError, after the 200 was already sent.
+	streamErrStatus int
+}
+
+func newStatusRecorder(w http.ResponseWriter) *statusRecorder {
+	return &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (w *statusRecorder) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// findRecorder returns the statusRecorder of the metrics under w, unwrapping
+// the writers of middlewares and BufferResponse.
+func findRecorder(w http.ResponseWriter) (*statusRecorder, bool) {
+	for {
+		if rec, ok := w.(*statusRecorder); ok {
+			return rec, true
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil, false
+		}
+		w = u.Unwrap()
+	}
+}
+
+// metricStatus is the status used to label the request in metrics.
+func (w *statusRecorder) metricStatus() int {
+	if w.disconnected {
+		return statusClientClosedRequest
+	}
+	if w.streamErrStatus != 0 {
+		return w.streamErrStatus
+	}
+	return w.status
+}
+
+// Unwrap returns the wrapped writer, for findRecorder and
+// http.ResponseController.
+func (w *statusRecorder) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("corekit: response writer does not support hijacking")
+	}
+	if !w.wroteHeader {
+		w.status = http.StatusSwitchingProtocols
+		w.wroteHeader = true
+	}
+	return h.Hijack()
+}
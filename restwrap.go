@@ -1,53 +1,119 @@
 package corekit
 
 import (
-	"encoding/json"
 	"net/http"
+	"reflect"
+	"strconv"
 
-	"github.com/pkg/errors"
-	"github.com/t-ksn/core-kit/apierror"
+	"github.com/t-ksn/core-kit/jsoncodec"
 )
 
 // API Handler
 type APIHandler func(req *http.Request) (interface{}, error)
 
-func wrapAPIHandler(log func(format string, args ...interface{})) func(handler APIHandler) http.Handler {
+// wrapAPIHandler writes the result of handler as JSON. HEAD requests get the
+// same status and headers, Content-Length included, without the body.
+// Slices and arrays encoding to more than streamOver bytes are streamed
+// element by element, without Content-Length, and counted in
+// http_oversized_responses_total; streamOver <= 0 disables streaming. With
+// bufferMax > 0 the response is buffered as by BufferResponse, so that an
+// error streaming a slice still gets its status.
+func wrapAPIHandler(log func(format string, args ...interface{}), errs errorHandler, codec jsoncodec.Codec, streamOver, bufferMax int) func(handler APIHandler) http.Handler {
+	var oversized func(values ...string)
+	if streamOver > 0 {
+		oversized = registerCounter(log, "http_oversized_responses_total",
+			"Number of responses streamed because they exceeded the buffering threshold, by method and route.", []string{"method", "path"})
+	}
 	return func(handler APIHandler) http.Handler {
 		wrap := func(w http.ResponseWriter, r *http.Request) {
 			var ok bool
+			var bw *bufferedWriter
+			if bufferMax > 0 {
+				bw, r = newBufferedWriter(w, r, bufferMax)
+				defer bw.flush()
+				w = bw
+			}
 			w.Header().Set("Content-Type", "application/json")
 
 			result, err := handler(r)
 			if err != nil {
-				var apiErr apierror.APIError
-
-				innerErr := errors.Cause(err)
-				if apiErr, ok = innerErr.(apierror.APIError); !ok {
-					log("[ERROR] API wrapper: %+v", err)
-					apiErr = apierror.InternalServerErr
-				}
-				w.WriteHeader(apiErr.StatusCode)
-
-				if apiErr.StatusCode != http.StatusBadRequest {
+				if isClientDisconnect(r, err) {
+					markDisconnected(w)
 					return
 				}
-
-				b, _ := json.Marshal(apiErr)
-				w.Write(b)
+				errs.handle(w, r, err)
 				return
 			}
 
+			if hr, ok := result.(HeaderResponse); ok {
+				for k, v := range hr.Header {
+					w.Header()[k] = v
+				}
+				result = hr.Body
+			}
+
 			if result == nil {
 				w.WriteHeader(http.StatusCreated)
 				return
 			}
 
-			w.WriteHeader(http.StatusOK)
+			if result == NoContent {
+				w.Header().Del("Content-Type")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if rd, ok := result.(RedirectResponse); ok {
+				w.Header().Del("Content-Type")
+				w.Header().Set("Location", rd.URL)
+				if rd.Code == 0 {
+					rd.Code = http.StatusFound
+				}
+				w.WriteHeader(rd.Code)
+				return
+			}
+
 			var body []byte
 			if body, ok = result.([]byte); !ok {
-				body, _ = json.Marshal(result)
+				if rv := reflect.ValueOf(result); streamOver > 0 && streamable(rv) {
+					var streamed bool
+					body, streamed, err = encodeSlice(w, r, codec, rv, streamOver)
+					if streamed {
+						route := routePattern(r)
+						if route == "" {
+							route = "other"
+						}
+						oversized(r.Method, route)
+						if err != nil {
+							if isClientDisconnect(r, err) {
+								markDisconnected(w)
+								return
+							}
+							if bw != nil && bw.discard() {
+								w.Header().Set("Content-Type", "application/json")
+								errs.handle(w, r, err)
+								return
+							}
+							log("[ERROR] API wrapper: stream response: %+v", err)
+						}
+						return
+					}
+				} else {
+					body, _ = codec.Marshal(result)
+				}
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(http.StatusOK)
+			if r.Method == http.MethodHead {
+				return
+			}
+			if _, err = w.Write(body); err != nil {
+				if isClientDisconnect(r, err) {
+					markDisconnected(w)
+					return
+				}
+				log("[ERROR] API wrapper: write response: %+v", err)
 			}
-			w.Write(body)
 		}
 
 		return http.HandlerFunc(wrap)
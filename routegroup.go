@@ -0,0 +1,73 @@
+package corekit
+
+import "net/http"
+
+// RouteGroup registers routes sharing a path prefix and middlewares.
+type RouteGroup interface {
+	Get(path string, handler APIHandler)
+	Post(path string, handler APIHandler)
+	Put(path string, handler APIHandler)
+	Del(path string, handler APIHandler)
+	Stream(path string, handler StreamAPIHandler)
+	EventStream(path string, handler EventStreamHandler)
+	LongPoll(path string, handler APIHandler)
+	RPC(path string, handlers map[string]RPCHandler)
+	// Group creates a nested group; its middlewares run after the parent's.
+	Group(prefix string, mws ...Middleware) RouteGroup
+}
+
+type routeGroup struct {
+	s      *service
+	prefix string
+	mws    []Middleware
+}
+
+func (s *service) Group(prefix string, mws ...Middleware) RouteGroup {
+	return &routeGroup{s: s, prefix: prefix, mws: mws}
+}
+
+func (g *routeGroup) add(meth, path string, h http.Handler) {
+	g.s.add(meth, g.prefix+path, chain(h, g.mws...))
+}
+
+func (g *routeGroup) Get(path string, handler APIHandler) {
+	h := g.s.wrapAPIHandler(handler)
+	g.add(http.MethodGet, path, h)
+	g.add(http.MethodHead, path, h)
+}
+
+func (g *routeGroup) Post(path string, handler APIHandler) {
+	g.add(http.MethodPost, path, g.s.wrapAPIHandler(handler))
+}
+
+func (g *routeGroup) Put(path string, handler APIHandler) {
+	g.add(http.MethodPut, path, g.s.wrapAPIHandler(handler))
+}
+
+func (g *routeGroup) Del(path string, handler APIHandler) {
+	g.add(http.MethodDelete, path, g.s.wrapAPIHandler(handler))
+}
+
+func (g *routeGroup) Stream(path string, handler StreamAPIHandler) {
+	g.add(http.MethodGet, path, g.s.streamAPIHandler(handler))
+}
+
+func (g *routeGroup) EventStream(path string, handler EventStreamHandler) {
+	g.add(http.MethodGet, path, g.s.eventStream(handler))
+}
+
+func (g *routeGroup) LongPoll(path string, handler APIHandler) {
+	g.add(http.MethodGet, path, g.s.longPoll(handler))
+}
+
+func (g *routeGroup) RPC(path string, handlers map[string]RPCHandler) {
+	g.add(http.MethodPost, path, rpcHandler(g.s.errs, g.s.options.jsonCodec, handlers))
+}
+
+func (g *routeGroup) Group(prefix string, mws ...Middleware) RouteGroup {
+	return &routeGroup{
+		s:      g.s,
+		prefix: g.prefix + prefix,
+		mws:    append(append([]Middleware{}, g.mws...), mws...),
+	}
+}
@@ -0,0 +1,58 @@
+package corekit
+
+import "net/http"
+
+// SecurityHeadersOptions overrides the headers set by SecurityHeaders. An empty
+// field keeps the default value, "-" omits the header entirely.
+type SecurityHeadersOptions struct {
+	ContentTypeOptions      string
+	FrameOptions            string
+	StrictTransportSecurity string
+	ReferrerPolicy          string
+	ContentSecurityPolicy   string
+}
+
+var defaultSecurityHeaders = SecurityHeadersOptions{
+	ContentTypeOptions:      "nosniff",
+	FrameOptions:            "DENY",
+	StrictTransportSecurity: "max-age=63072000; includeSubDomains",
+	ReferrerPolicy:          "no-referrer",
+	ContentSecurityPolicy:   "default-src 'none'; frame-ancestors 'none'",
+}
+
+// SecurityHeaders sets common security response headers. Strict-Transport-Security
+// is only sent on HTTPS requests.
+func SecurityHeaders(opts SecurityHeadersOptions) Middleware {
+	headers := [][2]string{
+		{"X-Content-Type-Options", pickHeader(opts.ContentTypeOptions, defaultSecurityHeaders.ContentTypeOptions)},
+		{"X-Frame-Options", pickHeader(opts.FrameOptions, defaultSecurityHeaders.FrameOptions)},
+		{"Referrer-Policy", pickHeader(opts.ReferrerPolicy, defaultSecurityHeaders.ReferrerPolicy)},
+		{"Content-Security-Policy", pickHeader(opts.ContentSecurityPolicy, defaultSecurityHeaders.ContentSecurityPolicy)},
+	}
+	hsts := pickHeader(opts.StrictTransportSecurity, defaultSecurityHeaders.StrictTransportSecurity)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			for _, kv := range headers {
+				if kv[1] != "" {
+					h.Set(kv[0], kv[1])
+				}
+			}
+			if r.TLS != nil && hsts != "" {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func pickHeader(val, def string) string {
+	switch val {
+	case "":
+		return def
+	case "-":
+		return ""
+	}
+	return val
+}
@@ -0,0 +1,103 @@
+package corekit
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// selfChecker detects a wedged accept loop by sending requests to the service's
+// own listener. Any HTTP response counts as success.
+type selfChecker struct {
+	interval time.Duration
+	https    bool
+	up       gauge
+	log      func(format string, args ...interface{})
+
+	mu  sync.Mutex
+	err error
+}
+
+func newSelfChecker(interval time.Duration, https bool, log func(format string, args ...interface{})) *selfChecker {
+	return &selfChecker{
+		interval: interval,
+		https:    https,
+		log:      log,
+		up: registerGauge(log, "http_server_self_check_up",
+			"Whether the last self check against the server's own listener succeeded."),
+	}
+}
+
+func (c *selfChecker) run(ctx context.Context, addr net.Addr) {
+	client := &http.Client{
+		Timeout: c.interval,
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true}, // dialing ourselves
+			DisableKeepAlives: true,
+		},
+	}
+	url := fmt.Sprint("http://", dialAddr(addr), "/health")
+	if c.https {
+		url = fmt.Sprint("https://", dialAddr(addr), "/health")
+	}
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("User-Agent", "corekit-self-check")
+		resp, err := client.Do(req.WithContext(ctx))
+		if err == nil {
+			resp.Body.Close()
+		} else if ctx.Err() != nil {
+			return
+		}
+		c.record(err)
+	}
+}
+
+func (c *selfChecker) record(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		if c.err == nil {
+			c.log("[ERROR] Self check failed: %v\n", err)
+		}
+		c.err = errors.Wrap(err, "self check")
+		c.up.Set(0)
+		return
+	}
+	c.err = nil
+	c.up.Set(1)
+}
+
+func (c *selfChecker) check(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// dialAddr replaces an unspecified listen host with loopback.
+func dialAddr(addr net.Addr) string {
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
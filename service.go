@@ -1,28 +1,74 @@
+// Package corekit builds HTTP services with metrics, health checks and graceful
+// shutdown. It requires Go 1.20 or later, as shutdown errors are joined with
+// errors.Join.
 package corekit
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
-	"github.com/bmizerany/pat"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/t-ksn/core-kit/httpclient"
+	"github.com/t-ksn/core-kit/jsoncodec"
 )
 
 type Service interface {
+	// Get serves handler with GET and HEAD.
 	Get(path string, handler APIHandler)
 	Post(path string, handler APIHandler)
 	Put(path string, handler APIHandler)
 	Del(path string, handler APIHandler)
 	Stream(path string, handler StreamAPIHandler)
-
+	// EventStream serves handler over Server-Sent Events or NDJSON.
+	EventStream(path string, handler EventStreamHandler)
+	// LongPoll serves handler with GET, holding the request for up to the
+	// LongPollWait; a nil result is answered with 204 No Content.
+	LongPoll(path string, handler APIHandler)
+	// RPC serves the JSON-RPC 2.0 methods of handlers with POST on path.
+	RPC(path string, handlers map[string]RPCHandler)
+	// Group registers routes under a common prefix wrapped by mws.
+	Group(prefix string, mws ...Middleware) RouteGroup
+	// Fallback handles every request that does not match a registered route.
+	Fallback(handler APIHandler)
+	// Static serves the files of dir under prefix.
+	Static(prefix string, dir string)
+	// StaticFS serves fsys under prefix. With spaFallback, unknown paths are
+	// answered with index.html for single page applications.
+	StaticFS(prefix string, fsys fs.FS, spaFallback bool)
+	// ServeMux returns the mux routes are registered on. With the default mux
+	// it implements PatRouter, giving access to the underlying pat router.
+	ServeMux() ServeMux
+	// Health returns the aggregator of the HealthCheck checks served on
+	// /health, e.g. to run them from a CLI command.
+	Health() *HealthAggregator
+
+	// Run serves until SIGINT/SIGTERM or the ShutdownTrigger fires. It installs
+	// its own signal handler, so it is only safe in single-service binaries; use
+	// RunContext or Group otherwise. If the port cannot be bound, it logs why
+	// and exits the process with status 1.
 	Run()
+	// RunContext serves until ctx is done and then gracefully shuts down.
+	// Signal handling is left to the caller. A port already bound by another
+	// process is reported as an error matching ErrAddressInUse.
+	RunContext(ctx context.Context) error
+	// Stop gracefully shuts down the running service, e.g. on a fatal error
+	// detected by the service itself.
+	Stop(ctx context.Context) error
+	// Server returns the running http.Server, or nil when the service is not
+	// running, e.g. to call SetKeepAlivesDisabled.
+	Server() *http.Server
 }
 
 type ServeMux interface {
@@ -35,7 +81,7 @@ type Option func(o *Options)
 type Options struct {
 	name             string
 	version          string
-	dependenciesInfo map[string]func() interface{}
+	dependenciesInfo map[string]func(ctx context.Context) interface{}
 	params           map[string]string
 	port             int
 	certFile         string
@@ -43,6 +89,53 @@ type Options struct {
 	serveMux         ServeMux
 	httpsEnabled     bool
 	logger           func(format string, args ...interface{})
+	healthChecks     map[string]HealthCheckFunc
+	healthCacheTTL   time.Duration
+	healthTimeout    time.Duration
+	configureServer  func(s *http.Server)
+	middlewares      []Middleware
+	problemDetails   bool
+	problemMapper    ProblemMapper
+	listener         net.Listener
+	selfCheck        time.Duration
+	infoFields       map[string]func() interface{}
+	trustedProxies   []*net.IPNet
+	deadlineHeader   string
+	maxHeaderBytes   int
+	onPanic          PanicHandler
+	errorMappers     []ErrorMapper
+	slowRequest      time.Duration
+	requestIDs       bool
+	metricLabels     []string
+	envPrefix        *string
+	readTimeout      time.Duration
+	writeTimeout     time.Duration
+	idleTimeout      time.Duration
+	shutdownTimeout  time.Duration
+	shutdownDelay    time.Duration
+	tlsConfig        *tls.Config
+	certProvider     func() (*tls.Certificate, error)
+	jsonCodec        jsoncodec.Codec
+	builtinMws       []Middleware
+	onConnState      func(net.Conn, http.ConnState)
+	favicon          *[]byte
+	decoders         map[string]BodyDecoder
+	maxURLLength     int
+	maxHeaderLength  int
+	shutdownTrigger  <-chan struct{}
+	maxBodyBytes     int64
+	certSet          *CertificateSet
+	shutdownHooks    []func(ctx context.Context) error
+	traceID          TraceIDFunc
+	debugConfig      *[]Middleware
+	longPollWait     time.Duration
+	recordMetrics    func(m *httpMetrics, log func(format string, args ...interface{}))
+	proxyProtocol    bool
+	workers          []func(ctx context.Context) error
+	externalURL      *url.URL
+	streamOver       int
+	bufferAPI        int
+	useNumber        bool
 }
 
 func Name(n string) Option {
@@ -58,11 +151,29 @@ func Version(v string) Option {
 }
 
 func DependencyInfo(name string, f func() interface{}) Option {
+	return func(o *Options) {
+		if f == nil {
+			panic(fmt.Sprintf("corekit: DependencyInfo(%q) with nil function", name))
+		}
+		o.dependenciesInfo[name] = func(context.Context) interface{} { return f() }
+	}
+}
+
+// DependencyInfoCtx is like DependencyInfo, but f gets the /info request
+// context so it can stop when the request is cancelled or times out.
+func DependencyInfoCtx(name string, f func(ctx context.Context) interface{}) Option {
 	return func(o *Options) {
 		o.dependenciesInfo[name] = f
 	}
 }
 
+// InfoField adds a top-level field computed by f to the /info response.
+func InfoField(key string, f func() interface{}) Option {
+	return func(o *Options) {
+		o.infoFields[key] = f
+	}
+}
+
 func Param(name, val string) Option {
 	return func(o *Options) {
 		o.params[name] = val
@@ -83,6 +194,42 @@ func Https(certFile, keyFile string) Option {
 	}
 }
 
+// TLSConfig enables HTTPS with cfg. Certificates may come from cfg or from
+// the Https and TLSCertProvider options.
+func TLSConfig(cfg *tls.Config) Option {
+	return func(o *Options) {
+		o.tlsConfig = cfg
+		o.httpsEnabled = true
+	}
+}
+
+// TLSCertProvider enables HTTPS with the certificate returned by f. It is
+// called for every TLS handshake, so rotated certificates are picked up by new
+// connections without a restart; f should return a cached certificate.
+func TLSCertProvider(f func() (*tls.Certificate, error)) Option {
+	return func(o *Options) {
+		o.certProvider = f
+		o.httpsEnabled = true
+	}
+}
+
+// TLSCertificates enables HTTPS with the certificates of set, chosen by the
+// host name requested by the client. It takes precedence over
+// TLSCertProvider.
+func TLSCertificates(set *CertificateSet) Option {
+	return func(o *Options) {
+		o.certSet = set
+		o.httpsEnabled = true
+	}
+}
+
+// JSONCodec replaces encoding/json for response bodies and /info.
+func JSONCodec(c jsoncodec.Codec) Option {
+	return func(o *Options) {
+		o.jsonCodec = c
+	}
+}
+
 func UseServeMux(mux ServeMux) Option {
 	return func(o *Options) {
 		o.serveMux = mux
@@ -95,104 +242,751 @@ func Logger(l func(format string, args ...interface{})) Option {
 	}
 }
 
-func NewService(opts ...Option) Service {
+func HealthCheck(name string, f HealthCheckFunc) Option {
+	return func(o *Options) {
+		o.healthChecks[name] = f
+	}
+}
+
+func HealthCacheTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.healthCacheTTL = ttl
+	}
+}
+
+func HealthTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.healthTimeout = timeout
+	}
+}
+
+func ConfigureServer(f func(s *http.Server)) Option {
+	return func(o *Options) {
+		o.configureServer = f
+	}
+}
+
+func MaxHeaderBytes(n int) Option {
+	return func(o *Options) {
+		o.maxHeaderBytes = n
+	}
+}
+
+// OnPanic is called with every panic recovered from a route handler.
+func OnPanic(f PanicHandler) Option {
+	return func(o *Options) {
+		o.onPanic = f
+	}
+}
+
+// MapErrors adds mappers used to translate handler errors that are not an
+// apierror.APIError; see the grpcerror package for gRPC status errors.
+func MapErrors(mappers ...ErrorMapper) Option {
+	return func(o *Options) {
+		o.errorMappers = append(o.errorMappers, mappers...)
+	}
+}
+
+// SlowRequestLog logs a warning for every request taking longer than threshold.
+func SlowRequestLog(threshold time.Duration) Option {
+	return func(o *Options) {
+		o.slowRequest = threshold
+	}
+}
+
+// RequestIDs assigns every request an ID (reusing a valid X-Request-ID from the
+// client), returns it in the response and makes it available through RequestID.
+func RequestIDs() Option {
+	return func(o *Options) {
+		o.requestIDs = true
+	}
+}
+
+// MetricLabels declares extra labels of the HTTP metrics that handlers can set
+// with AddMetricLabel. Keep the possible values of each label few.
+func MetricLabels(keys ...string) Option {
+	return func(o *Options) {
+		o.metricLabels = append(o.metricLabels, keys...)
+	}
+}
+
+// TraceIDFunc returns the trace ID of the span found in ctx, if any. It lets
+// corekit use whichever tracing library the service is instrumented with.
+type TraceIDFunc func(ctx context.Context) (string, bool)
+
+// Exemplars attaches the trace ID returned by traceID to the samples of the
+// http_request_duration_seconds histogram, linking latency to traces. They are
+// exposed when /metrics is scraped in the OpenMetrics format.
+func Exemplars(traceID TraceIDFunc) Option {
+	return func(o *Options) {
+		o.traceID = traceID
+	}
+}
+
+// LongPollWait is the longest a LongPoll request is held, 30s by default. It
+// should stay below the WriteTimeout and the timeouts of proxies in front.
+func LongPollWait(d time.Duration) Option {
+	return func(o *Options) {
+		o.longPollWait = d
+	}
+}
+
+// StreamResponsesOver is the size above which a slice or array returned by an
+// APIHandler is no longer buffered to set Content-Length but encoded and sent
+// element by element, keeping memory bounded for huge results, e.g. 8<<20.
+// Such responses are counted in http_oversized_responses_total. Streaming is
+// disabled by default.
+func StreamResponsesOver(bytes int) Option {
+	return func(o *Options) {
+		o.streamOver = bytes
+	}
+}
+
+// BufferAPIResponses buffers up to maxBytes of every response of an APIHandler
+// so that an error occurring while the body is written, e.g. when streaming a
+// slice (see StreamResponsesOver), still produces a proper error status.
+// Larger responses are passed through from that point. Event streams are not
+// buffered. maxBytes defaults to 1MB.
+func BufferAPIResponses(maxBytes int) Option {
+	return func(o *Options) {
+		if maxBytes <= 0 {
+			maxBytes = 1 << 20
+		}
+		o.bufferAPI = maxBytes
+	}
+}
+
+// DebugConfig serves the effective configuration on /debug/config: timeouts,
+// limits, TLS source, middlewares and registered routes, without file paths,
+// secrets or parameter values. It is wrapped by mws, e.g. an authentication
+// middleware, after the BuiltinMiddleware ones.
+func DebugConfig(mws ...Middleware) Option {
+	return func(o *Options) {
+		o.debugConfig = &mws
+	}
+}
+
+// BuiltinMiddleware wraps only the built-in endpoints (/health, /ready,
+// /info, /metrics, /debug/config), e.g. to protect them with authentication.
+func BuiltinMiddleware(mws ...Middleware) Option {
+	return func(o *Options) {
+		o.builtinMws = append(o.builtinMws, mws...)
+	}
+}
+
+// Use adds middlewares around every route, the first one being the outermost.
+// They run after the middlewares enabled by options unless DefaultMiddlewares
+// is passed to place those explicitly.
+func Use(mws ...Middleware) Option {
+	return func(o *Options) {
+		o.middlewares = append(o.middlewares, mws...)
+	}
+}
+
+// ProblemDetails reports handler errors as RFC 7807 application/problem+json.
+// A nil mapper uses apierror.ToProblem.
+func ProblemDetails(mapper ProblemMapper) Option {
+	return func(o *Options) {
+		o.problemDetails = true
+		o.problemMapper = mapper
+	}
+}
+
+// Listener makes the service serve on ln instead of listening on Port.
+func Listener(ln net.Listener) Option {
+	return func(o *Options) {
+		o.listener = ln
+	}
+}
+
+// ProxyProtocol expects every connection, whether accepted on Port or on the
+// Listener, to start with a PROXY protocol header (version 1 or 2), as sent by
+// HAProxy or AWS NLB, so that r.RemoteAddr is the address of the client rather
+// than the one of the balancer. Connections without it are closed, except from
+// loopback addresses. Only enable it when every peer is such a balancer.
+func ProxyProtocol() Option {
+	return func(o *Options) {
+		o.proxyProtocol = true
+	}
+}
+
+// SelfCheck periodically sends a request to the service's own listener and
+// reports /health as failing when it does not get an answer.
+func SelfCheck(interval time.Duration) Option {
+	return func(o *Options) {
+		o.selfCheck = interval
+	}
+}
+
+// TrustedProxies makes Forwarded and X-Forwarded-* headers count when the
+// request comes from one of the given networks (CIDR or single IP). See
+// Scheme, Host and ClientIP.
+func TrustedProxies(networks ...string) Option {
+	return func(o *Options) {
+		for _, n := range networks {
+			if !strings.Contains(n, "/") {
+				if strings.Contains(n, ":") {
+					n += "/128"
+				} else {
+					n += "/32"
+				}
+			}
+			_, ipNet, err := net.ParseCIDR(n)
+			if err != nil {
+				panic(fmt.Sprintf("corekit: invalid trusted proxy %q: %v", n, err))
+			}
+			o.trustedProxies = append(o.trustedProxies, ipNet)
+		}
+	}
+}
+
+// ExternalURL is the URL clients reach the service at, such as
+// "https://api.example.com/billing", used by BaseURL and URLFor instead of the
+// scheme and host of the request.
+func ExternalURL(rawURL string) Option {
+	return func(o *Options) {
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			panic(fmt.Sprintf("corekit: invalid external URL %q", rawURL))
+		}
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		u.RawQuery, u.Fragment = "", ""
+		o.externalURL = u
+	}
+}
+
+// PropagateDeadline bounds the request context by the timeout the caller sent
+// in header (httpclient.DefaultDeadlineHeader when empty).
+func PropagateDeadline(header string) Option {
+	return func(o *Options) {
+		if header == "" {
+			header = httpclient.DefaultDeadlineHeader
+		}
+		o.deadlineHeader = header
+	}
+}
+
+func ReadTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.readTimeout = d
+	}
+}
+
+func WriteTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.writeTimeout = d
+	}
+}
+
+func IdleTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.idleTimeout = d
+	}
+}
 
+// ShutdownTimeout bounds the graceful shutdown. Defaults to 5s.
+func ShutdownTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.shutdownTimeout = d
+	}
+}
+
+// LimitRequestHeaders rejects requests with an URL longer than maxURL or a
+// header line longer than maxHeader bytes with 431 Request Header Fields Too
+// Large. Zero values default to 8KB.
+func LimitRequestHeaders(maxURL, maxHeader int) Option {
+	return func(o *Options) {
+		if maxURL <= 0 {
+			maxURL = defaultMaxURLLength
+		}
+		if maxHeader <= 0 {
+			maxHeader = defaultMaxHeaderLength
+		}
+		o.maxURLLength, o.maxHeaderLength = maxURL, maxHeader
+	}
+}
+
+// MaxBodyBytes limits request bodies to n bytes. Bind and RawBody fail with
+// apierror.BodyTooLargeErr (413) on larger bodies.
+func MaxBodyBytes(n int64) Option {
+	return func(o *Options) {
+		o.maxBodyBytes = n
+	}
+}
+
+// Decoder makes Bind decode bodies of the given content type (e.g.
+// "application/msgpack") with decode, replacing the built-in decoder if any.
+func Decoder(contentType string, decode BodyDecoder) Option {
+	return func(o *Options) {
+		if o.decoders == nil {
+			o.decoders = map[string]BodyDecoder{}
+		}
+		o.decoders[contentType] = decode
+	}
+}
+
+// UseNumber makes Bind and StreamBody decode JSON numbers held in interface{}
+// values, e.g. map[string]interface{} bodies, as json.Number instead of
+// float64, so that large IDs and decimal amounts keep their precision. The
+// JSONCodec must implement jsoncodec.NumberUnmarshaler, as jsoncodec.Std does.
+func UseNumber() Option {
+	return func(o *Options) {
+		o.useNumber = true
+	}
+}
+
+// SuppressFavicon answers /favicon.ico with 204 No Content so browsers do not
+// produce 404s in logs and metrics.
+func SuppressFavicon() Option {
+	return Favicon(nil)
+}
+
+// Favicon serves icon on /favicon.ico.
+func Favicon(icon []byte) Option {
+	return func(o *Options) {
+		o.favicon = &icon
+	}
+}
+
+// OnShutdown registers hook to run, in registration order, once the server
+// stopped serving, e.g. to close database pools. ctx bounds the whole
+// shutdown. The errors of all hooks and of the server shutdown are returned
+// together by RunContext and Stop.
+func OnShutdown(hook func(ctx context.Context) error) Option {
+	return func(o *Options) {
+		o.shutdownHooks = append(o.shutdownHooks, hook)
+	}
+}
+
+// Worker registers f to run in its own goroutine, alongside the server, once
+// it listens. Its context is cancelled on shutdown, after the server stopped
+// serving and before the OnShutdown hooks, and the shutdown waits for f to
+// return. A worker returning an error before that shuts the service down, the
+// error being returned by RunContext.
+func Worker(f func(ctx context.Context) error) Option {
+	return func(o *Options) {
+		o.workers = append(o.workers, f)
+	}
+}
+
+// ShutdownTrigger makes Run shut down when trigger is closed or receives a
+// value, as it does on SIGINT/SIGTERM, so tests can stop it deterministically.
+func ShutdownTrigger(trigger <-chan struct{}) Option {
+	return func(o *Options) {
+		o.shutdownTrigger = trigger
+	}
+}
+
+// OnConnState is called on every connection state change, after the built-in
+// http_server_connections gauge is updated.
+func OnConnState(f func(net.Conn, http.ConnState)) Option {
+	return func(o *Options) {
+		o.onConnState = f
+	}
+}
+
+// ShutdownDelay keeps serving for d after shutdown starts, with /ready
+// reporting draining, so load balancers stop routing to the instance before
+// it closes its listener.
+func ShutdownDelay(d time.Duration) Option {
+	return func(o *Options) {
+		o.shutdownDelay = d
+	}
+}
+
+func newOptions() *Options {
 	defaultLogger := log.New(os.Stdout, "", log.LUTC|log.LstdFlags|log.Lshortfile)
 
-	options := &Options{
-		dependenciesInfo: map[string]func() interface{}{},
+	return &Options{
+		dependenciesInfo: map[string]func(ctx context.Context) interface{}{},
 		params:           map[string]string{},
-		serveMux:         &adoptPatRouter{pat.New()},
+		infoFields:       map[string]func() interface{}{},
+		serveMux:         newPatRouter(),
 		logger:           defaultLogger.Printf,
+		healthChecks:     map[string]HealthCheckFunc{},
+		healthCacheTTL:   5 * time.Second,
+		healthTimeout:    3 * time.Second,
+		shutdownTimeout:  5 * time.Second,
+		jsonCodec:        jsoncodec.Std{},
+		longPollWait:     30 * time.Second,
 	}
+}
 
+func NewService(opts ...Option) Service {
+	options := newOptions()
 	for _, o := range opts {
 		o(options)
 	}
+	if options.envPrefix != nil {
+		applyEnv(options, *options.envPrefix)
+	}
+	if options.serveMux == nil {
+		options.serveMux = newPatRouter()
+	}
+	if options.logger == nil {
+		options.logger = newOptions().logger
+	}
+	if options.jsonCodec == nil {
+		options.jsonCodec = jsoncodec.Std{}
+	}
+	decoders := defaultDecoders(options.jsonCodec, options.useNumber)
+	for ct, decode := range options.decoders {
+		decoders[ct] = decode
+	}
+	options.decoders = decoders
+	options.mustBeValid()
+
+	errs := errorHandler{
+		log:     options.logger,
+		mappers: options.errorMappers,
+		write:   writeAPIError,
+		codec:   options.jsonCodec,
+	}
+	if options.problemDetails {
+		errs.write = problemErrorWriter(options.problemMapper)
+	}
+
+	var selfCheck *selfChecker
+	if options.selfCheck > 0 {
+		selfCheck = newSelfChecker(options.selfCheck, options.httpsEnabled, options.logger)
+		options.healthChecks["self"] = selfCheck.check
+	}
+
+	metrics := newHTTPMetrics(options.logger, options.metricLabels, options.traceID)
+	if options.recordMetrics != nil {
+		options.recordMetrics(metrics, options.logger)
+	}
 
 	service := &service{
 		options:          *options,
-		wrapAPIHandler:   wrapAPIHandler(options.logger),
-		streamAPIHandler: streamWrapAPIHandler(options.logger),
+		wrapAPIHandler:   wrapAPIHandler(options.logger, errs, options.jsonCodec, options.streamOver, options.bufferAPI),
+		streamAPIHandler: streamWrapAPIHandler(options.logger, errs),
+		eventStream:      eventStreamWrapAPIHandler(errs, options.jsonCodec),
+		metrics:          metrics,
+		selfCheck:        selfCheck,
+		recoverer:        recoverer(options.logger, options.onPanic, errs),
+		errs:             errs,
+		readiness:        newReadiness(),
+		health:           newHealthAggregator(options),
+		stopping:         make(chan struct{}),
 	}
 
-	service.options.serveMux.Add(http.MethodGet, "/health", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}))
+	service.addBuiltin("/health", healthHandler(service.health))
 
-	service.options.serveMux.Add(http.MethodGet, "/info", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("content-type", "application/json")
-		dp := map[string]interface{}{}
-		for name, d := range options.dependenciesInfo {
-			dp[name] = d()
-		}
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"name":         options.name,
-			"version":      options.version,
-			"params":       options.params,
-			"dependencies": dp,
-		})
-	}))
+	service.addBuiltin("/ready", readyHandler(service.readiness, options.jsonCodec))
 
-	service.options.serveMux.Add(http.MethodGet, "/metrics", promhttp.Handler())
+	service.addBuiltin("/info", infoHandler(options))
+
+	if h := metricsHandler(); h != nil {
+		service.addBuiltin("/metrics", skipIfDisconnected(h))
+	}
+
+	if options.debugConfig != nil {
+		service.addBuiltin("/debug/config", chain(debugConfigHandler(service), *options.debugConfig...))
+	}
+
+	if options.favicon != nil {
+		options.serveMux.Add(http.MethodGet, "/favicon.ico", faviconHandler(*options.favicon))
+	}
 
 	return service
 }
 
+// mustBeValid panics on nil values that would otherwise only fail at request time.
+func (o *Options) mustBeValid() {
+	for name, f := range o.healthChecks {
+		if f == nil {
+			panic(fmt.Sprintf("corekit: HealthCheck(%q) with nil check", name))
+		}
+	}
+	for name, f := range o.dependenciesInfo {
+		if f == nil {
+			panic(fmt.Sprintf("corekit: DependencyInfoCtx(%q) with nil function", name))
+		}
+	}
+	for name, f := range o.infoFields {
+		if f == nil {
+			panic(fmt.Sprintf("corekit: InfoField(%q) with nil function", name))
+		}
+	}
+	for ct, f := range o.decoders {
+		if f == nil {
+			panic(fmt.Sprintf("corekit: Decoder(%q) with nil function", ct))
+		}
+	}
+	if _, ok := o.jsonCodec.(jsoncodec.NumberUnmarshaler); o.useNumber && !ok {
+		panic(fmt.Sprintf("corekit: UseNumber with codec %T not implementing jsoncodec.NumberUnmarshaler", o.jsonCodec))
+	}
+	for i, hook := range o.shutdownHooks {
+		if hook == nil {
+			panic(fmt.Sprintf("corekit: OnShutdown with nil hook at position %d", i))
+		}
+	}
+	for i, f := range o.workers {
+		if f == nil {
+			panic(fmt.Sprintf("corekit: Worker with nil function at position %d", i))
+		}
+	}
+	for i, mw := range o.middlewares {
+		if mw == nil {
+			panic(fmt.Sprintf("corekit: Use with nil middleware at position %d", i))
+		}
+	}
+}
+
 type service struct {
 	options          Options
 	wrapAPIHandler   func(handler APIHandler) http.Handler
 	streamAPIHandler func(handler StreamAPIHandler) http.Handler
+	eventStream      func(handler EventStreamHandler) http.Handler
+	metrics          *httpMetrics
+	selfCheck        *selfChecker
+	recoverer        Middleware
+	errs             errorHandler
+	readiness        *readiness
+	health           *HealthAggregator
+	// stopping is closed, once, when shutdown starts, ending long polls
+	// whether they started before or after.
+	stopping     chan struct{}
+	stoppingOnce sync.Once
+
+	// mu guards run, the state of the server while Run or RunContext is
+	// active, and routes, the "METHOD path" of every registered route.
+	mu     sync.Mutex
+	run    *runState
+	routes []string
+}
+
+// handler wraps the mux with the built-in middlewares followed by the ones
+// registered with Use. See DefaultMiddlewares for the order.
+func (s *service) handler() http.Handler {
+	var defaults []Middleware
+	if s.options.maxURLLength > 0 {
+		defaults = append(defaults, limitRequestHeaders(s.options.maxURLLength, s.options.maxHeaderLength))
+	}
+	if s.options.maxBodyBytes > 0 {
+		defaults = append(defaults, limitBody(s.options.maxBodyBytes))
+	}
+	if len(s.options.trustedProxies) > 0 {
+		defaults = append(defaults, trustProxies(s.options.trustedProxies))
+	}
+	if s.options.requestIDs {
+		defaults = append(defaults, assignRequestID)
+	}
+	if s.options.deadlineHeader != "" {
+		defaults = append(defaults, propagateDeadline(s.options.deadlineHeader))
+	}
+	if s.options.slowRequest > 0 {
+		defaults = append(defaults, slowRequestLog(s.options.logger, s.options.slowRequest))
+	}
+
+	mws := []Middleware{s.errs.inject, injectParams(s.options.params), injectDecoders(s.options.decoders, s.options.useNumber)}
+	if s.options.externalURL != nil {
+		mws = append(mws, injectExternalURL(s.options.externalURL))
+	}
+	return chain(chainUser(s.options.serveMux, s.options.middlewares, defaults), mws...)
+}
+
+// addBuiltin registers one of the built-in endpoints wrapped by the
+// BuiltinMiddleware middlewares.
+func (s *service) addBuiltin(path string, h http.Handler) {
+	s.options.serveMux.Add(http.MethodGet, path, chain(h, s.options.builtinMws...))
+}
+
+func (s *service) add(meth, path string, h http.Handler) {
+	s.mu.Lock()
+	s.routes = append(s.routes, meth+" "+path)
+	s.mu.Unlock()
+	s.options.serveMux.Add(meth, path, s.metrics.instrument(meth, path, s.recoverer(h)))
 }
 
 func (s *service) Get(path string, handler APIHandler) {
-	s.options.serveMux.Add(http.MethodGet, path, s.wrapAPIHandler(handler))
+	h := s.wrapAPIHandler(handler)
+	s.add(http.MethodGet, path, h)
+	s.add(http.MethodHead, path, h)
 }
 
 func (s *service) Post(path string, handler APIHandler) {
-	s.options.serveMux.Add(http.MethodPost, path, s.wrapAPIHandler(handler))
+	s.add(http.MethodPost, path, s.wrapAPIHandler(handler))
 }
 func (s *service) Put(path string, handler APIHandler) {
-	s.options.serveMux.Add(http.MethodPut, path, s.wrapAPIHandler(handler))
+	s.add(http.MethodPut, path, s.wrapAPIHandler(handler))
 }
 func (s *service) Del(path string, handler APIHandler) {
-	s.options.serveMux.Add(http.MethodDelete, path, s.wrapAPIHandler(handler))
+	s.add(http.MethodDelete, path, s.wrapAPIHandler(handler))
 }
 
 func (s *service) Stream(path string, handler StreamAPIHandler) {
-	s.options.serveMux.Add(http.MethodGet, path, s.streamAPIHandler(handler))
+	s.add(http.MethodGet, path, s.streamAPIHandler(handler))
+}
+
+func (s *service) EventStream(path string, handler EventStreamHandler) {
+	s.add(http.MethodGet, path, s.eventStream(handler))
+}
+
+func (s *service) RPC(path string, handlers map[string]RPCHandler) {
+	s.add(http.MethodPost, path, rpcHandler(s.errs, s.options.jsonCodec, handlers))
+}
+
+func (s *service) Fallback(handler APIHandler) {
+	s.fallback(s.wrapAPIHandler(handler))
+}
+
+func (s *service) ServeMux() ServeMux {
+	return s.options.serveMux
+}
+
+func (s *service) Health() *HealthAggregator {
+	return s.health
+}
+
+// fallback installs h as the mux NotFound handler. Muxes that do not support
+// it get h registered on "/" for every method, which in pat matches any path
+// not claimed by a route registered before it.
+func (s *service) fallback(h http.Handler) {
+	h = s.metrics.instrument("", "fallback", s.recoverer(h))
+	if mux, ok := s.options.serveMux.(interface{ NotFound(h http.Handler) }); ok {
+		mux.NotFound(h)
+		return
+	}
+	for _, meth := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions} {
+		s.options.serveMux.Add(meth, "/", h)
+	}
 }
 
 func (s *service) Run() {
-	s.options.logger("[INFO] Start listening address :%v\n", s.options.port)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if trigger := s.options.shutdownTrigger; trigger != nil {
+		go func() {
+			select {
+			case <-trigger:
+				stop()
+			case <-ctx.Done():
+			}
+		}()
+	}
 
+	// Errors of a service that started are logged by RunContext.
+	if err := s.RunContext(ctx); err != nil {
+		var lerr *listenError
+		if errors.As(err, &lerr) {
+			s.options.logger("[ERROR] %+v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func (s *service) RunContext(ctx context.Context) error {
+	ln := s.options.listener
+	if ln == nil {
+		var err error
+		if ln, err = listen(s.options.port); err != nil {
+			return err
+		}
+	}
+	if s.options.proxyProtocol {
+		ln = proxyListener{ln}
+	}
+	s.options.logger("[INFO] Start listening address %v\n", ln.Addr())
+
+	conns := &connTracker{gauge: s.metrics.addConnections}
+	connState := conns.track
+	if onConnState := s.options.onConnState; onConnState != nil {
+		connState = func(c net.Conn, state http.ConnState) {
+			conns.track(c, state)
+			onConnState(c, state)
+		}
+	}
 	server := http.Server{
-		Addr:    fmt.Sprint(":", s.options.port),
-		Handler: s.options.serveMux,
+		Addr:           ln.Addr().String(),
+		Handler:        s.handler(),
+		ConnState:      connState,
+		MaxHeaderBytes: s.options.maxHeaderBytes,
+		ReadTimeout:    s.options.readTimeout,
+		WriteTimeout:   s.options.writeTimeout,
+		IdleTimeout:    s.options.idleTimeout,
+	}
+	if s.options.tlsConfig != nil || s.options.certProvider != nil || s.options.certSet != nil {
+		server.TLSConfig = &tls.Config{}
+		if s.options.tlsConfig != nil {
+			server.TLSConfig = s.options.tlsConfig.Clone()
+		}
+		if provider := s.options.certProvider; provider != nil {
+			server.TLSConfig.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				return provider()
+			}
+		}
+		if s.options.certSet != nil {
+			server.TLSConfig.GetCertificate = s.options.certSet.GetCertificate
+		}
+	}
+	if s.options.configureServer != nil {
+		s.options.configureServer(&server)
 	}
 
-	ch := make(chan os.Signal)
-	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	errCh := make(chan error, 1)
 	go func() {
-		<-ch
-		s.options.logger("[INFO] Graceful shutdown...\n")
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-
-		if err := server.Shutdown(ctx); err != nil {
-			s.options.logger("[ERROR] %+v\n", err)
+		if s.options.httpsEnabled {
+			errCh <- server.ServeTLS(ln, s.options.certFile, s.options.keyFile)
+		} else {
+			errCh <- server.Serve(ln)
 		}
+	}()
 
-		s.options.logger("[INFO] Service stoped\n")
+	run := &runState{server: &server, conns: conns, workers: startWorkers(s.options.logger, s.options.workers)}
+	s.mu.Lock()
+	s.run = run
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.run = nil
+		s.mu.Unlock()
 	}()
+	s.readiness.ready()
+
+	if s.selfCheck != nil {
+		checkCtx, stopCheck := context.WithCancel(ctx)
+		defer stopCheck()
+		go s.selfCheck.run(checkCtx, ln.Addr())
+	}
 
 	var err error
-	if s.options.httpsEnabled {
-		err = server.ListenAndServeTLS(s.options.certFile, s.options.keyFile)
-	} else {
-		err = server.ListenAndServe()
+	select {
+	case err = <-errCh:
+		if err != http.ErrServerClosed {
+			// Serving failed: still stop the workers and run the hooks.
+			err = errors.Join(err, s.shutdownWithin(run))
+			break
+		}
+		// Stopped with Stop: wait for its shutdown to complete.
+		err = run.shutdown(s, ctx)
+	case <-ctx.Done():
+		err = s.shutdownAfter(run, errCh)
+	case <-run.workers.failed:
+		s.options.logger("[INFO] Worker failed, shutting down\n")
+		err = s.shutdownAfter(run, errCh)
+	}
+	if err != nil {
+		s.options.logger("[ERROR] Service: %v\n", strings.ReplaceAll(err.Error(), "\n", "; "))
 	}
-	if err != nil && err != http.ErrServerClosed {
-		s.options.logger("[ERROR] %+v\n", err)
+	s.options.logger("[INFO] Service stoped\n")
+	return err
+}
+
+// shutdownWithin shuts run down within the ShutdownDelay and ShutdownTimeout.
+func (s *service) shutdownWithin(run *runState) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.options.shutdownDelay+s.options.shutdownTimeout)
+	defer cancel()
+	return run.shutdown(s, ctx)
+}
+
+// shutdownAfter shuts run down within the ShutdownDelay and ShutdownTimeout
+// and adds any unexpected error of the server, reported on errCh.
+func (s *service) shutdownAfter(run *runState, errCh <-chan error) error {
+	err := s.shutdownWithin(run)
+	if serveErr := <-errCh; serveErr != http.ErrServerClosed {
+		err = errors.Join(err, serveErr)
 	}
+	return err
 }
@@ -0,0 +1,187 @@
+package corekit
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// captureMaxBytes is the largest response kept by captureWriter for replay.
+const captureMaxBytes = 1 << 20
+
+// Singleflight coalesces concurrent GET and HEAD requests with the same key:
+// the handler runs once and its buffered response, error responses included,
+// is replayed to every request that arrived meanwhile. An empty key, from
+// keyFunc or for other methods, bypasses coalescing.
+//
+// Responses larger than 1MB, flushed or hijacked (streams) are not shared, nor
+// are they when the handler panicked or its client went away; waiting
+// requests then run the handler themselves.
+func Singleflight(keyFunc func(r *http.Request) string) Middleware {
+	if keyFunc == nil {
+		panic("corekit: Singleflight with nil keyFunc")
+	}
+	g := &flightGroup{calls: map[string]*flightCall{}}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var key string
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				key = keyFunc(r)
+			}
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key = r.Method + " " + key
+
+			c, leader := g.join(key)
+			if !leader {
+				select {
+				case <-c.done:
+				case <-r.Context().Done():
+					return
+				}
+				if !c.shared {
+					next.ServeHTTP(w, r)
+					return
+				}
+				c.replay(w)
+				return
+			}
+
+			fw := &captureWriter{ResponseWriter: w, header: http.Header{}}
+			defer func() {
+				p := recover()
+				c.shared = p == nil && !fw.passthrough && r.Context().Err() == nil
+				c.status, c.header, c.body = fw.status, fw.header, fw.buf.Bytes()
+				g.leave(key, c)
+				if p != nil {
+					panic(p)
+				}
+			}()
+			next.ServeHTTP(fw, r)
+			fw.flush()
+		})
+	}
+}
+
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done   chan struct{}
+	shared bool
+	status int
+	header http.Header
+	body   []byte
+}
+
+// join returns the call in flight for key, or starts one and reports true.
+func (g *flightGroup) join(key string) (*flightCall, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if c, ok := g.calls[key]; ok {
+		return c, false
+	}
+	c := &flightCall{done: make(chan struct{})}
+	g.calls[key] = c
+	return c, true
+}
+
+func (g *flightGroup) leave(key string, c *flightCall) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(c.done)
+}
+
+func (c *flightCall) replay(w http.ResponseWriter) {
+	for k, v := range c.header {
+		w.Header()[k] = append([]string(nil), v...)
+	}
+	if c.status != 0 {
+		w.WriteHeader(c.status)
+	}
+	w.Write(c.body)
+}
+
+// captureWriter buffers a response so it can be replayed, falling back to
+// pass-through like bufferedWriter once it grows past captureMaxBytes, is
+// flushed or hijacked.
+type captureWriter struct {
+	http.ResponseWriter
+	header      http.Header
+	status      int
+	buf         bytes.Buffer
+	passthrough bool
+}
+
+func (w *captureWriter) Header() http.Header {
+	if w.passthrough {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *captureWriter) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	if !w.passthrough && w.buf.Len()+len(b) > captureMaxBytes {
+		w.flush()
+		w.passthrough = true
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+// flush sends the buffered response to the client, keeping the buffer for
+// replay.
+func (w *captureWriter) flush() {
+	if w.passthrough {
+		return
+	}
+	h := w.ResponseWriter.Header()
+	for k, v := range w.header {
+		h[k] = v
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	w.ResponseWriter.Write(w.buf.Bytes())
+}
+
+func (w *captureWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *captureWriter) Flush() {
+	w.flush()
+	w.passthrough = true
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *captureWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("corekit: response writer does not support hijacking")
+	}
+	w.passthrough = true
+	return h.Hijack()
+}
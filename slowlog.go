@@ -0,0 +1,19 @@
+package corekit
+
+import (
+	"net/http"
+	"time"
+)
+
+func slowRequestLog(log func(format string, args ...interface{}), threshold time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := newStatusRecorder(w)
+			next.ServeHTTP(rec, r)
+			if d := time.Since(start); d > threshold {
+				log("[WARN] Slow request: %s %s status: %d duration: %v\n", r.Method, r.URL.Path, rec.status, d)
+			}
+		})
+	}
+}
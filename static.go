@@ -0,0 +1,45 @@
+package corekit
+
+import (
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+func (s *service) Static(prefix string, dir string) {
+	s.StaticFS(prefix, os.DirFS(dir), false)
+}
+
+func (s *service) StaticFS(prefix string, fsys fs.FS, spaFallback bool) {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	h := http.StripPrefix(strings.TrimSuffix(prefix, "/"), staticHandler(fsys, spaFallback))
+	s.add(http.MethodGet, prefix, h)
+	s.add(http.MethodHead, prefix, h)
+}
+
+// staticHandler serves files from fsys. With spaFallback, paths that do not
+// exist are answered with the root index.html so client side routing works.
+func staticHandler(fsys fs.FS, spaFallback bool) http.Handler {
+	files := http.FileServer(http.FS(fsys))
+	if !spaFallback {
+		return files
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if name == "" {
+			name = "."
+		}
+		if _, err := fs.Stat(fsys, name); err != nil {
+			r2 := *r
+			u := *r.URL
+			u.Path = "/"
+			r2.URL = &u
+			r = &r2
+		}
+		files.ServeHTTP(w, r)
+	})
+}
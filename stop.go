@@ -0,0 +1,126 @@
+package corekit
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var errNotRunning = errors.New("corekit: service is not running")
+
+// drainLogInterval is how often the requests still in flight are logged while
+// the server shuts down.
+const drainLogInterval = time.Second
+
+// runState is the server of a running service, shut down once by whichever
+// of RunContext and Stop comes first.
+type runState struct {
+	server  *http.Server
+	conns   *connTracker
+	workers *workers
+	once    sync.Once
+	err     error
+}
+
+func (rs *runState) shutdown(s *service, ctx context.Context) error {
+	rs.once.Do(func() {
+		rs.err = s.shutdown(ctx, rs)
+	})
+	return rs.err
+}
+
+// Stop gracefully shuts down the service started with Run or RunContext,
+// waiting for in-flight requests until ctx is done. RunContext returns once
+// the shutdown completes.
+func (s *service) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	run := s.run
+	s.mu.Unlock()
+	if run == nil {
+		return errNotRunning
+	}
+	return run.shutdown(s, ctx)
+}
+
+func (s *service) Server() *http.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.run == nil {
+		return nil
+	}
+	return s.run.server
+}
+
+// shutdown reports the service as draining, waits for the ShutdownDelay, shuts
+// the server down until ctx is done, logging the requests in flight meanwhile,
+// stops the workers and runs the OnShutdown hooks. Their errors are joined with
+// the one of server.Shutdown.
+func (s *service) shutdown(ctx context.Context, run *runState) error {
+	s.readiness.drain("shutdown", s.options.shutdownDelay+s.options.shutdownTimeout)
+	if s.options.shutdownDelay > 0 {
+		s.options.logger("[INFO] Draining for %v before shutdown\n", s.options.shutdownDelay)
+		select {
+		case <-time.After(s.options.shutdownDelay):
+		case <-ctx.Done():
+		}
+	}
+
+	inFlight := s.metrics.requestsInFlight()
+	s.metrics.shutdownInFlight.Set(float64(inFlight))
+	s.options.logger("[INFO] Graceful shutdown... (requests in flight: %v)\n", inFlight)
+	s.stoppingOnce.Do(func() { close(s.stopping) })
+
+	var errs []error
+	start := time.Now()
+	drained := make(chan struct{})
+	if inFlight > 0 {
+		go s.logDrain(ctx, drained)
+	}
+	err := run.server.Shutdown(ctx)
+	close(drained)
+	s.metrics.shutdownDuration.Set(time.Since(start).Seconds())
+	if err == context.DeadlineExceeded {
+		s.options.logger("[WARN] Shutdown timeout, connections still open: %v\n", run.conns.open())
+	}
+	if err != nil {
+		errs = append(errs, errors.Wrap(err, "shutdown server"))
+	}
+
+	if err := run.workers.stop(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	for i, hook := range s.options.shutdownHooks {
+		if err := hook(ctx); err != nil {
+			errs = append(errs, errors.Wrapf(err, "shutdown hook %d", i))
+		}
+	}
+	return stderrors.Join(errs...)
+}
+
+// logDrain logs the number of requests in flight, as counted for the
+// http_requests_in_flight gauge, every drainLogInterval until it reaches zero,
+// ctx is done or drained is closed.
+func (s *service) logDrain(ctx context.Context, drained <-chan struct{}) {
+	t := time.NewTicker(drainLogInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-drained:
+			return
+		case <-ctx.Done():
+			return
+		}
+		inFlight := s.metrics.requestsInFlight()
+		if inFlight == 0 {
+			s.options.logger("[INFO] All requests drained\n")
+			return
+		}
+		s.options.logger("[INFO] Draining... (requests in flight: %v)\n", inFlight)
+	}
+}
@@ -0,0 +1,66 @@
+package corekit
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"net/http"
+
+	"github.com/t-ksn/core-kit/apierror"
+)
+
+// StreamBody returns a decoder reading the request body as it arrives, for
+// NDJSON or concatenated JSON values, e.g. bulk imports too large to buffer:
+//
+//	dec := corekit.StreamBody(r)
+//	for {
+//		var item Item
+//		if err := dec.Decode(&item); err == io.EOF {
+//			break
+//		} else if err != nil {
+//			return nil, corekit.StreamBodyError(err)
+//		}
+//		...
+//	}
+//
+// Neither wrapAPIHandler nor the default middlewares read the body before the
+// handler, but MaxBodyBytes still applies to the whole stream: reading past
+// it fails with apierror.BodyTooLargeErr. With the UseNumber option, numbers
+// are decoded as json.Number.
+func StreamBody(r *http.Request) *json.Decoder {
+	dec := json.NewDecoder(bodyReader{r.Body})
+	if useNumber, _ := r.Context().Value(useNumberKey{}).(bool); useNumber {
+		dec.UseNumber()
+	}
+	return dec
+}
+
+// StreamBodyError maps an error of a StreamBody decoder to the APIError to
+// return: apierror.BodyTooLargeErr past MaxBodyBytes, apierror.JSONInvalidErr
+// for malformed values. Other errors are returned as is.
+func StreamBodyError(err error) error {
+	var syntax *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	if _, ok := err.(apierror.APIError); ok {
+		return err
+	}
+	switch {
+	case stderrors.As(err, &syntax), stderrors.As(err, &typ), stderrors.Is(err, io.ErrUnexpectedEOF):
+		return apierror.JSONInvalidErr
+	}
+	return err
+}
+
+// bodyReader reports reads past MaxBodyBytes as apierror.BodyTooLargeErr.
+type bodyReader struct {
+	io.Reader
+}
+
+func (b bodyReader) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	var tooLarge *http.MaxBytesError
+	if stderrors.As(err, &tooLarge) {
+		return n, apierror.BodyTooLargeErr
+	}
+	return n, err
+}
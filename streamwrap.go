@@ -1,13 +1,11 @@
 package corekit
 
 import (
-	"encoding/json"
+	"context"
 	"net/http"
 	"time"
 
 	"github.com/gorilla/websocket"
-	"github.com/pkg/errors"
-	"github.com/t-ksn/core-kit/apierror"
 )
 
 type StreamAPIHandler func(req *http.Request) (receiver chan []byte, cancel chan struct{}, err error)
@@ -34,34 +32,29 @@ const (
 	maxMessageSize = 1024
 )
 
-func streamWrapAPIHandler(log func(format string, args ...interface{})) func(handler StreamAPIHandler) http.Handler {
+// streamWrapAPIHandler serves a StreamAPIHandler over a websocket. The request
+// passed to the handler carries a context that is cancelled as soon as the
+// client disconnects or the stream ends, so producers can stop by watching
+// req.Context().Done() instead of (or in addition to) the cancel channel.
+func streamWrapAPIHandler(log func(format string, args ...interface{}), errs errorHandler) func(handler StreamAPIHandler) http.Handler {
 	return func(handler StreamAPIHandler) http.Handler {
 		wrap := func(w http.ResponseWriter, r *http.Request) {
-			var ok bool
+			DisableCompression(r)
 			w.Header().Set("Content-Type", "application/json")
 
-			receiver, cancel, err := handler(r)
-			if err != nil {
-				var apiErr apierror.APIError
-
-				innerErr := errors.Cause(err)
-				if apiErr, ok = innerErr.(apierror.APIError); !ok {
-					log("[ERROR] API wrapper: %+v", err)
-					apiErr = apierror.InternalServerErr
-				}
-				w.WriteHeader(apiErr.StatusCode)
+			ctx, stop := context.WithCancel(r.Context())
+			defer stop()
 
-				if apiErr.StatusCode != http.StatusBadRequest {
-					return
-				}
-
-				b, _ := json.Marshal(apiErr)
-				w.Write(b)
+			receiver, cancel, err := handler(r.WithContext(ctx))
+			if err != nil {
+				errs.handle(w, r, err)
 				return
 			}
 
 			wsConn, err := defaultUpgrader.Upgrade(w, r, nil)
 			if err != nil {
+				stop()
+				closeStream(receiver, cancel)
 				return
 			}
 
@@ -69,30 +62,37 @@ func streamWrapAPIHandler(log func(format string, args ...interface{})) func(han
 			wsConn.SetReadDeadline(time.Now().Add(pongWait))
 			wsConn.SetPongHandler(func(string) error { wsConn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
 
+			go func() { // handles pong and close frames, detects client disconnect
+				for {
+					if _, _, err := wsConn.ReadMessage(); err != nil {
+						stop()
+						return
+					}
+				}
+			}()
+
 			ticker := time.NewTicker(pingPeriod)
-			chWSClosed := make(chan struct{}, 2)
+			defer ticker.Stop()
 			for {
 				select {
-				case data := <-receiver:
+				case data, ok := <-receiver:
+					if !ok {
+						receiver = nil
+						stop()
+						continue
+					}
 					wsConn.SetWriteDeadline(time.Now().Add(writeWait))
-					err = wsConn.WriteMessage(websocket.BinaryMessage, data)
-					if err != nil {
-						chWSClosed <- struct{}{}
+					if err = wsConn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+						stop()
 					}
 				case <-ticker.C:
 					if err := wsConn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(writeWait)); err != nil {
-						chWSClosed <- struct{}{}
+						stop()
 					}
-				case <-chWSClosed:
-					cancel <- struct{}{}
+				case <-ctx.Done():
+					closeStream(receiver, cancel)
 					wsConn.WriteControl(websocket.CloseMessage, []byte{}, time.Now().Add(writeWait))
 					wsConn.Close()
-					close(chWSClosed)
-
-					go func(r chan []byte) { // read all rest messages to /dev/null
-						for _ = range r {
-						}
-					}(receiver)
 					return
 				}
 			}
@@ -101,3 +101,19 @@ func streamWrapAPIHandler(log func(format string, args ...interface{})) func(han
 		return http.HandlerFunc(wrap)
 	}
 }
+
+// closeStream drains the rest of the messages to /dev/null and notifies the
+// handler through its cancel channel. Nothing is sent once the handler has
+// closed the receiver itself.
+func closeStream(receiver chan []byte, cancel chan struct{}) {
+	if receiver == nil {
+		return
+	}
+	go func(r chan []byte) {
+		for range r {
+		}
+	}(receiver)
+	if cancel != nil {
+		cancel <- struct{}{}
+	}
+}
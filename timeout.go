@@ -0,0 +1,44 @@
+package corekit
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/t-ksn/core-kit/apierror"
+)
+
+type timeoutKey struct{}
+
+// Timeout cancels the request context after d, for routes needing a deadline
+// other than the service wide one, e.g.
+//
+//	svc.Group("/reports", corekit.Timeout(2*time.Minute)).Get("/:id", report)
+//
+// Any error returned by the handler once d has passed is reported as
+// apierror.GatewayTimeoutErr, as is a handler returning without a response.
+// The handler is not interrupted: it must watch its context.
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			r = r.WithContext(context.WithValue(ctx, timeoutKey{}, ctx))
+
+			rec, ok := w.(*statusRecorder)
+			if !ok {
+				rec = newStatusRecorder(w)
+			}
+			next.ServeHTTP(rec, r)
+			if !rec.wroteHeader && timedOut(r) {
+				WriteError(rec, r, apierror.GatewayTimeoutErr)
+			}
+		})
+	}
+}
+
+// timedOut reports whether the deadline set by Timeout for r has passed.
+func timedOut(r *http.Request) bool {
+	ctx, ok := r.Context().Value(timeoutKey{}).(context.Context)
+	return ok && ctx.Err() == context.DeadlineExceeded
+}
@@ -0,0 +1,81 @@
+package corekit
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/t-ksn/core-kit/apierror"
+)
+
+// Versioned dispatches a route to handlers by the API version requested in
+// the Accept header as a vendor media type, "application/vnd.<vendor>.v<N>+json",
+// e.g. with vendor "myapp":
+//
+//	svc.Get("/users/:id", corekit.Versioned("myapp", map[int]corekit.APIHandler{1: getUserV1, 2: getUserV2}, 0))
+//
+// Requests without a vendor media type get version def, or the latest one when
+// def is 0. A version without handler is answered with
+// apierror.NotAcceptableErr. The response Content-Type names the version
+// served, and Vary: Accept is set for caches.
+func Versioned(vendor string, handlers map[int]APIHandler, def int) APIHandler {
+	if len(handlers) == 0 {
+		panic("corekit: Versioned without handlers")
+	}
+	if def == 0 {
+		for v := range handlers {
+			if v > def {
+				def = v
+			}
+		}
+	}
+	if _, ok := handlers[def]; !ok {
+		panic(fmt.Sprintf("corekit: Versioned default version %d without handler", def))
+	}
+
+	return func(r *http.Request) (interface{}, error) {
+		v, ok := AcceptedVersion(r, vendor)
+		if !ok {
+			v = def
+		}
+		h, ok := handlers[v]
+		if !ok {
+			return nil, apierror.NotAcceptableErr
+		}
+		result, err := h(r)
+		if err != nil {
+			return result, err
+		}
+
+		hr, ok := result.(HeaderResponse)
+		if !ok {
+			hr = HeaderResponse{Body: result}
+		}
+		if hr.Header == nil {
+			hr.Header = http.Header{}
+		}
+		hr.Header.Set("Content-Type", fmt.Sprintf("application/vnd.%s.v%d+json", vendor, v))
+		hr.Header.Add("Vary", "Accept")
+		return hr, nil
+	}
+}
+
+// AcceptedVersion returns the version N of the first
+// "application/vnd.<vendor>.v<N>+json" media type in the Accept header of r.
+func AcceptedVersion(r *http.Request, vendor string) (int, bool) {
+	prefix := "application/vnd." + strings.ToLower(vendor) + ".v"
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mt, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil || !strings.HasPrefix(mt, prefix) || !strings.HasSuffix(mt, "+json") {
+				continue
+			}
+			if v, err := strconv.Atoi(strings.TrimSuffix(mt[len(prefix):], "+json")); err == nil && v > 0 {
+				return v, true
+			}
+		}
+	}
+	return 0, false
+}
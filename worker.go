@@ -0,0 +1,64 @@
+package corekit
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// workers runs the Worker functions of a running service.
+type workers struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	// failed is closed when a worker returns an error before being stopped.
+	failed     chan struct{}
+	failedOnce sync.Once
+
+	mu   sync.Mutex
+	errs []error
+}
+
+func startWorkers(log func(format string, args ...interface{}), fs []func(ctx context.Context) error) *workers {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &workers{cancel: cancel, failed: make(chan struct{})}
+	for i, f := range fs {
+		w.wg.Add(1)
+		go func(i int, f func(ctx context.Context) error) {
+			defer w.wg.Done()
+			err := f(ctx)
+			if err == nil || (ctx.Err() != nil && stderrors.Is(err, context.Canceled)) {
+				return
+			}
+			log("[ERROR] Worker %d: %+v\n", i, err)
+			w.mu.Lock()
+			w.errs = append(w.errs, errors.Wrapf(err, "worker %d", i))
+			w.mu.Unlock()
+			w.failedOnce.Do(func() { close(w.failed) })
+		}(i, f)
+	}
+	return w
+}
+
+// stop cancels the workers and waits for them to return until ctx is done.
+// It returns the errors of the workers that failed.
+func (w *workers) stop(ctx context.Context) error {
+	w.cancel()
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		w.mu.Lock()
+		w.errs = append(w.errs, errors.New("workers still running at shutdown timeout"))
+		w.mu.Unlock()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return stderrors.Join(w.errs...)
+}